@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/robbyriverside/brevity/internal/brevity"
 	"github.com/robbyriverside/brevity/internal/generator"
@@ -21,5 +22,6 @@ func main() {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
 			return
 		}
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,453 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robbyriverside/brevity/internal/brevity"
+	"github.com/robbyriverside/brief"
+
+	"github.com/google/shlex"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultShell runs shell actions when neither the action nor the --shell flag names one
+func DefaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd /C"
+	}
+	return "sh -c"
+}
+
+// shells other than the sh like ones an action may name without a path
+var otherShells = wordSet(`cmd fish powershell pwsh`)
+
+// ValidShell checks the shell key of an action, true or false, or a shell command run by
+// its absolute path or by the name of a known shell, so a typo is not run as a program
+func ValidShell(value string) error {
+	if value == "true" || value == "false" {
+		return nil
+	}
+	args, err := shlex.Split(value)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("shell is empty")
+	}
+	name := strings.TrimSuffix(args[0], ".exe")
+	if !filepath.IsAbs(args[0]) && !scriptShells[name] && !otherShells[name] {
+		return fmt.Errorf("shell must be true, false, or a known shell or its absolute path: %s", value)
+	}
+	return nil
+}
+
+// ActionShell is the shell an action runs through, empty when it is exec'd directly.
+// An action with a script in its content always runs through a shell.
+func ActionShell(action *brief.Node, shell string) string {
+	value, ok := action.Keys["shell"]
+	if value == "false" || (!ok && action.Content == "") {
+		return ""
+	}
+	if ok && value != "true" {
+		return value
+	}
+	if shell != "" {
+		return shell
+	}
+	return DefaultShell()
+}
+
+// ActionArgs expands the exec command or script of an action for this spec node.
+// For a builtin action they are the builtin name followed by its expanded args,
+// and for a generate action the spec file and the folder it generates into.
+func (gtor *Generator) ActionArgs(action, spec *brief.Node) ([]string, error) {
+	if _, ok := action.Keys["generate"]; ok {
+		specfile, destination, err := gtor.ActionGenerate(action, spec, "")
+		if err != nil {
+			return nil, err
+		}
+		return []string{specfile, destination}, nil
+	}
+	if builtin, ok := action.Keys["builtin"]; ok {
+		args, err := gtor.ExecValueTemplate(action.Keys["args"], spec)
+		if err != nil {
+			return nil, err
+		}
+		split, err := shlex.Split(args)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{builtin}, split...), nil
+	}
+	exectmpl, ok := action.Keys["exec"]
+	if action.Content != "" {
+		exectmpl, ok = action.Content, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("action %s has no exec", action.Name)
+	}
+
+	execute, err := gtor.ExecValueTemplate(exectmpl, spec)
+	if err != nil {
+		return nil, err
+	}
+	if shell := ActionShell(action, gtor.Shell); shell != "" {
+		args, err := shlex.Split(shell)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("action %s has empty shell", action.Name)
+		}
+		return append(args, execute), nil
+	}
+	args, err := shlex.Split(execute)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("action %s has empty exec", action.Name)
+	}
+	return args, nil
+}
+
+// ActionDir is the working directory of an action, the project folder or its dir key beneath it
+func (gtor *Generator) ActionDir(action, spec *brief.Node, dir string) (string, error) {
+	subdir, ok := action.Keys["dir"]
+	if !ok {
+		return dir, nil
+	}
+	subdir, err := gtor.ExecValueTemplate(subdir, spec)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(subdir) {
+		return "", fmt.Errorf("action %s dir %s must be relative to the project", action.Name, subdir)
+	}
+	actdir := filepath.Join(dir, subdir)
+	if !insideProject(dir, actdir) {
+		return "", fmt.Errorf("action %s dir %s is outside the project", action.Name, subdir)
+	}
+	return actdir, nil
+}
+
+// insideProject when path is the project folder or below it
+func insideProject(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ActionEnv expands the env child of an action into sorted KEY=value entries.
+// An env node named clean starts the action from an empty environment.
+func (gtor *Generator) ActionEnv(action, spec *brief.Node) (entries []string, clean bool, err error) {
+	env := action.Child("env")
+	if env == nil {
+		return nil, false, nil
+	}
+	if env.Name != "" && env.Name != "clean" {
+		return nil, false, fmt.Errorf("action %s env name must be clean or empty: %s", action.Name, env.Name)
+	}
+	keys := []string{}
+	for key := range env.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := gtor.ExecValueTemplate(env.Keys[key], spec)
+		if err != nil {
+			return nil, false, fmt.Errorf("action %s env %s: %s", action.Name, key, err)
+		}
+		entries = append(entries, fmt.Sprintf("%s=%s", key, value))
+	}
+	return entries, env.Name == "clean", nil
+}
+
+// ActionTimeout of an action from its timeout key, or the default when it has none
+func ActionTimeout(action *brief.Node, timeout time.Duration) (time.Duration, error) {
+	value, ok := action.Keys["timeout"]
+	if !ok {
+		return timeout, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("action %s timeout: %s", action.Name, err)
+	}
+	return duration, nil
+}
+
+// ActionGlobs expands a key of whitespace separated glob patterns
+func (gtor *Generator) ActionGlobs(action, spec *brief.Node, key string) ([]string, error) {
+	value, err := gtor.ExecValueTemplate(action.Keys[key], spec)
+	if err != nil {
+		return nil, fmt.Errorf("action %s %s: %s", action.Name, key, err)
+	}
+	return strings.Fields(value), nil
+}
+
+// SkipAction reports why an action need not run in dir, empty when it must run.
+// The hash of its inputs is returned to be recorded once the action succeeds.
+func (gtor *Generator) SkipAction(action, spec *brief.Node, dir string) (reason, inputs string, err error) {
+	if creates, ok := action.Keys["creates"]; ok {
+		name, err := gtor.ExecValueTemplate(creates, spec)
+		if err != nil {
+			return "", "", fmt.Errorf("action %s creates: %s", action.Name, err)
+		}
+		if _, err := os.Stat(resolve(dir, name)); err == nil {
+			return fmt.Sprintf("%s exists", name), "", nil
+		}
+	}
+	if _, ok := action.Keys["inputs"]; !ok {
+		return "", "", nil
+	}
+	patterns, err := gtor.ActionGlobs(action, spec, "inputs")
+	if err != nil {
+		return "", "", err
+	}
+	inputs, err = HashGlobs(dir, patterns)
+	if err != nil {
+		return "", "", fmt.Errorf("action %s inputs: %s", action.Name, err)
+	}
+	if inputs == "" {
+		// inputs that match nothing cannot show the action is up to date
+		return "", "", nil
+	}
+	if gtor.State == nil || gtor.State.InputsHash(action.Name, NodePath(spec)) != inputs {
+		return "", inputs, nil
+	}
+	outputs, err := gtor.ActionGlobs(action, spec, "outputs")
+	if err != nil {
+		return "", "", err
+	}
+	for _, pattern := range outputs {
+		matches, err := Glob(dir, pattern)
+		if err != nil {
+			return "", "", fmt.Errorf("action %s outputs: %s", action.Name, err)
+		}
+		if len(matches) == 0 {
+			return "", inputs, nil
+		}
+	}
+	return "inputs unchanged", inputs, nil
+}
+
+// ExecAction executes an action, retrying a failed one as its retries key allows.
+// Whether it is skipped or permitted to run is decided once, before the first attempt.
+func (gtor *Generator) ExecAction(ctx context.Context, action, spec *brief.Node, dir string) error {
+	retries, err := ActionRetries(action)
+	if err != nil {
+		return err
+	}
+	args, err := gtor.ActionArgs(action, spec)
+	if err != nil {
+		return err
+	}
+	actdir, err := gtor.ActionDir(action, spec, dir)
+	if err != nil {
+		return err
+	}
+	skip, inputs, err := gtor.SkipAction(action, spec, actdir)
+	if err != nil {
+		return err
+	}
+	_, builtin := action.Keys["builtin"]
+	_, generate := action.Keys["generate"]
+	if !generate && skip == "" {
+		label := fmt.Sprintf("%s on %s", action.Name, NodePath(spec))
+		// builtins change the project, so they are skipped and confirmed like commands
+		if builtin {
+			skip, err = gtor.Policy.PermitBuiltin(label, actdir, args)
+		} else {
+			skip, err = gtor.Policy.Permit(label, actdir, args)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if skip != "" {
+		if brevity.Options.Verbose {
+			fmt.Printf("action %s on %s:%s skipped: %s\n", action.Name, spec.Type, spec.Name, skip)
+		}
+		gtor.Log.Printf("=== action %s on %s skipped: %s\n", action.Name, NodePath(spec), skip)
+		if gtor.Manifest != nil {
+			gtor.Manifest.SkipAction(action.Name, NodePath(spec), gtor.Sources[action], args, skip)
+		}
+		// what uses the output of a skipped capture still expands, with a placeholder
+		if capture, ok := action.Keys["capture"]; ok && gtor.Captures != nil {
+			gtor.Captures.Set(capture, Placeholder(capture))
+		}
+		return nil
+	}
+	err = gtor.attemptAction(ctx, action, spec, dir, actdir, args, retries > 0)
+	for retry := 0; err != nil && retry < retries && ctx.Err() == nil; retry++ {
+		backoff := RetryBackoff << uint(retry)
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"action":  action.Name,
+			"node":    NodePath(spec),
+			"attempt": fmt.Sprintf("%d of %d", retry+1, retries+1),
+			"backoff": backoff,
+		}).Warn("action failed, retrying")
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+			err = gtor.attemptAction(ctx, action, spec, dir, actdir, args, retry+1 < retries)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	gtor.recordInputs(action, spec, inputs)
+	return nil
+}
+
+// attemptAction runs an action once in actdir.  A failure that will be retried is left for ExecAction to report.
+func (gtor *Generator) attemptAction(ctx context.Context, action, spec *brief.Node, dir, actdir string, args []string, retrying bool) error {
+	if _, ok := action.Keys["generate"]; ok {
+		return gtor.generateAction(ctx, action, spec, actdir)
+	}
+	if builtin, ok := action.Keys["builtin"]; ok {
+		if brevity.Options.Verbose {
+			fmt.Printf("action %s on %s:%s in %s builtin: %s\n", action.Name, spec.Type, spec.Name, actdir, strings.Join(args, " "))
+		}
+		gtor.Log.Printf("=== action %s on %s in %s builtin: %s\n", action.Name, NodePath(spec), actdir, strings.Join(args, " "))
+		err := RunBuiltin(builtin, BuiltinDir{Root: dir, Dir: actdir}, args[1:])
+		if gtor.Manifest != nil {
+			gtor.Manifest.AddAction(action.Name, NodePath(spec), gtor.Sources[action], args, err)
+		}
+		if err != nil {
+			gtor.Log.Printf("=== action %s failed: %s\n", action.Name, err)
+			return fmt.Errorf("action %s on %s:%s: %s", action.Name, spec.Type, spec.Name, err)
+		}
+		return nil
+	}
+	if brevity.Options.Verbose {
+		fmt.Printf("action %s on %s:%s in %s exec: %s\n", action.Name, spec.Type, spec.Name, actdir, strings.Join(args, " "))
+	}
+	entries, clean, err := gtor.ActionEnv(action, spec)
+	if err != nil {
+		return err
+	}
+	timeout, err := ActionTimeout(action, gtor.ActionTimeout)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = actdir
+	if clean {
+		// a nil Env inherits the whole environment, even for an env with no keys
+		cmd.Env = append([]string{}, entries...)
+	} else if len(entries) > 0 {
+		cmd.Env = append(os.Environ(), entries...)
+	}
+	out := NewActionOutput(fmt.Sprintf("%s %s", action.Name, NodeLabel(spec)), gtor.Log, brevity.Options.Verbose)
+	// actions running side by side write their output as one block when they finish
+	out.Group = gtor.Jobs > 1
+	gtor.Log.Printf("=== action %s on %s in %s exec: %s\n", action.Name, NodePath(spec), actdir, strings.Join(args, " "))
+	var stdout strings.Builder
+	capture, capturing := action.Keys["capture"]
+	if capturing {
+		err = runCommand(ctx, cmd, out, &stdout)
+	} else {
+		err = runCommand(ctx, cmd, out, nil)
+	}
+	out.Flush()
+	if err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			err = fmt.Errorf("action %s on %s:%s timed out after %s", action.Name, spec.Type, spec.Name, timeout)
+		case ctx.Err() == context.Canceled && Interrupted(ctx):
+			err = fmt.Errorf("action %s on %s:%s interrupted", action.Name, spec.Type, spec.Name)
+		case ctx.Err() == context.Canceled:
+			err = fmt.Errorf("action %s on %s:%s cancelled after another action failed", action.Name, spec.Type, spec.Name)
+		}
+		gtor.Log.Printf("=== action %s failed: %s\n", action.Name, err)
+	}
+	if gtor.Manifest != nil {
+		gtor.Manifest.AddAction(action.Name, NodePath(spec), gtor.Sources[action], args, err)
+	}
+	// tolerated failures are reported by RunAction, and retried ones by ExecAction
+	if err != nil && !retrying && ActionOnError(action) == OnErrorFail {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"output": string(out.Bytes()),
+			"action": action.Name,
+			"dir":    actdir,
+		}).Error("failed action")
+	}
+	if err != nil {
+		return err
+	}
+	if capturing && gtor.Captures != nil {
+		gtor.Captures.Set(capture, strings.TrimSpace(stdout.String()))
+	}
+	return nil
+}
+
+// generateAction runs the nested generation of a generate action
+func (gtor *Generator) generateAction(ctx context.Context, action, spec *brief.Node, dir string) error {
+	specfile, destination, err := gtor.ActionGenerate(action, spec, dir)
+	if err != nil {
+		return err
+	}
+	if brevity.Options.Verbose {
+		fmt.Printf("action %s on %s:%s generate: %s into %s\n", action.Name, spec.Type, spec.Name, specfile, destination)
+	}
+	gtor.Log.Printf("=== action %s on %s generate: %s into %s\n", action.Name, NodePath(spec), specfile, destination)
+	err = gtor.Generate(ctx, specfile, destination)
+	if gtor.Manifest != nil {
+		gtor.Manifest.AddAction(action.Name, NodePath(spec), gtor.Sources[action], []string{"generate", specfile, destination}, err)
+	}
+	if err != nil {
+		gtor.Log.Printf("=== action %s failed: %s\n", action.Name, err)
+		return fmt.Errorf("action %s on %s:%s: %s", action.Name, spec.Type, spec.Name, err)
+	}
+	return nil
+}
+
+// recordInputs of an action that succeeded, so that it is skipped until they change
+func (gtor *Generator) recordInputs(action, spec *brief.Node, inputs string) {
+	if inputs != "" && gtor.State != nil {
+		gtor.State.SetInputsHash(action.Name, NodePath(spec), inputs)
+	}
+}
+
+// runCommand in its own process group, killing the whole group when the context is done.
+// Stdout is also copied to capture when it is set.
+func runCommand(ctx context.Context, cmd *exec.Cmd, out io.Writer, capture io.Writer) error {
+	cmd.Stdout = out
+	if capture != nil {
+		cmd.Stdout = io.MultiWriter(out, capture)
+	}
+	cmd.Stderr = out
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestRetriedAction(t *testing.T) {
+	// fails the first time it runs, after making the file it creates
+	flaky := "sh -c 'touch made; test -f ran || { touch ran; exit 3; }'"
+	tests := []struct {
+		name    string
+		action  *brief.Node
+		confirm string
+		prompts int
+	}{
+		{"retried", testAction("flaky", "exec", flaky, "retries", "1"), "", 0},
+		{"creates left by a failure", testAction("flaky", "exec", flaky, "retries", "1", "creates", "made"), "", 0},
+		{"confirmed once", testAction("flaky", "exec", flaky, "retries", "1"), "y\nn\n", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gtor := (&Command{}).New()
+			gtor.Manifest = NewManifest("p", dir)
+			out := &bytes.Buffer{}
+			gtor.Policy = &ExecPolicy{Confirm: test.confirm != "", in: bufio.NewReader(strings.NewReader(test.confirm)), out: out}
+			spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+			if err := gtor.ExecAction(context.Background(), test.action, spec, dir); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "ran")); err != nil {
+				t.Fatal("the action never ran")
+			}
+			records := gtor.Manifest.Actions
+			if len(records) != 1 || records[0].Error != "" || records[0].Skipped != "" {
+				t.Errorf("got records %+v, want the final outcome only", records[0])
+			}
+			if prompts := strings.Count(out.String(), "[q]uit?"); prompts != test.prompts {
+				t.Errorf("prompted %d times, want %d", prompts, test.prompts)
+			}
+		})
+	}
+}
+
+func TestSkipActionRecord(t *testing.T) {
+	manifest := NewManifest("p", t.TempDir())
+	manifest.AddAction("build", "project:p", "go/generator.brief", []string{"go", "build"}, nil)
+	manifest.SkipAction("build", "project:p", "go/generator.brief", []string{"go", "build"}, "inputs unchanged")
+	manifest.SkipAction("build", "project:q", "go/generator.brief", []string{"go", "build"}, "inputs unchanged")
+	if len(manifest.Actions) != 2 || manifest.Actions[0].Skipped != "inputs unchanged" {
+		t.Errorf("got records %+v %+v", manifest.Actions[0], manifest.Actions[1])
+	}
+}
+
+func TestActionDir(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		subdir string
+		want   string
+		err    bool
+	}{
+		{"", dir, false},
+		{"cmd", filepath.Join(dir, "cmd"), false},
+		{"cmd/{{ .Name }}", filepath.Join(dir, "cmd", "p"), false},
+		{".", dir, false},
+		{"/tmp", "", true},
+		{"..", "", true},
+		{"cmd/../../x", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.subdir, func(t *testing.T) {
+			gtor := (&Command{}).New()
+			spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+			action := testAction("a")
+			if test.subdir != "" {
+				action.Keys["dir"] = test.subdir
+			}
+			got, err := gtor.ActionDir(action, spec, dir)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecActionDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "cmd"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gtor := (&Command{}).New()
+	spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+	action := testAction("where", "exec", "sh -c 'pwd > where.txt'", "dir", "cmd")
+	if err := gtor.ExecAction(context.Background(), action, spec, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cmd", "where.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := filepath.EvalSymlinks(filepath.Join(dir, "cmd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("ran in %s, want %s", got, want)
+	}
+	if now, _ := os.Getwd(); now != cwd {
+		t.Errorf("working directory changed to %s", now)
+	}
+}
+
+func TestActionEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   *brief.Node
+		want  string
+		clean bool
+		err   bool
+	}{
+		{"none", nil, "", false, false},
+		{"sorted", &brief.Node{Type: "env", Keys: map[string]string{"GOOS": "linux", "CGO_ENABLED": "0"}},
+			"CGO_ENABLED=0 GOOS=linux", false, false},
+		{"template", &brief.Node{Type: "env", Keys: map[string]string{"APP": "{{ .Name }}"}}, "APP=p", false, false},
+		{"clean", &brief.Node{Type: "env", Name: "clean", Keys: map[string]string{"PATH": "/bin"}}, "PATH=/bin", true, false},
+		{"clean without keys", &brief.Node{Type: "env", Name: "clean", Keys: map[string]string{}}, "", true, false},
+		{"bad name", &brief.Node{Type: "env", Name: "dirty", Keys: map[string]string{}}, "", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gtor := (&Command{}).New()
+			spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+			action := testAction("a")
+			if test.env != nil {
+				action.Body = []*brief.Node{test.env}
+			}
+			entries, clean, err := gtor.ActionEnv(action, spec)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if got := strings.Join(entries, " "); got != test.want || clean != test.clean {
+				t.Errorf("got %q clean %v, want %q clean %v", got, clean, test.want, test.clean)
+			}
+		})
+	}
+}
+
+func TestExecActionCleanEnv(t *testing.T) {
+	if err := os.Setenv("BREVITY_TEST_LEAK", "leaked"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("BREVITY_TEST_LEAK")
+	tests := []struct {
+		name string
+		keys map[string]string
+	}{
+		{"no keys", map[string]string{}},
+		{"with keys", map[string]string{"APP": "p"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gtor := (&Command{}).New()
+			spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+			action := testAction("env", "exec", `/bin/sh -c 'test -z "$BREVITY_TEST_LEAK"'`)
+			action.Body = []*brief.Node{{Type: "env", Name: "clean", Keys: test.keys}}
+			if err := gtor.ExecAction(context.Background(), action, spec, t.TempDir()); err != nil {
+				t.Errorf("environment inherited: %s", err)
+			}
+		})
+	}
+}
+
+func TestActionShell(t *testing.T) {
+	tests := []struct {
+		name    string
+		shell   string
+		content string
+		flag    string
+		want    string
+	}{
+		{"exec", "", "", "", ""},
+		{"exec with a shell flag", "", "", "bash -c", ""},
+		{"shell true", "true", "", "", DefaultShell()},
+		{"shell true with a flag", "true", "", "bash -c", "bash -c"},
+		{"shell false", "false", "", "bash -c", ""},
+		{"named shell", "zsh -c", "", "bash -c", "zsh -c"},
+		{"script", "", "go vet\ngo test", "", DefaultShell()},
+		{"script with a flag", "", "go vet", "bash -c", "bash -c"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			action := testAction("a")
+			action.Content = test.content
+			if test.shell != "" {
+				action.Keys["shell"] = test.shell
+			}
+			if got := ActionShell(action, test.flag); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidShell(t *testing.T) {
+	tests := []struct {
+		shell string
+		err   bool
+	}{
+		{"true", false},
+		{"false", false},
+		{"bash -c", false},
+		{"sh -ec", false},
+		{"pwsh -Command", false},
+		{"cmd.exe /C", false},
+		{"/usr/local/bin/fish -c", false},
+		{"ture", true},
+		{"yes", true},
+		{"./bash -c", true},
+		{"python -c", true},
+		{"", true},
+		{"'bash", true},
+	}
+	for _, test := range tests {
+		t.Run(test.shell, func(t *testing.T) {
+			if err := ValidShell(test.shell); (err != nil) != test.err {
+				t.Errorf("got error %v", err)
+			}
+			action := testAction("a", "shell", test.shell)
+			if err := ValidateAction(action, 0); (err != nil) != test.err {
+				t.Errorf("validate got error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Builtin action run inside brevity, paths in its args are relative to the action folder
+type Builtin func(bd BuiltinDir, args []string) error
+
+// BuiltinDir is the folder a builtin runs in and the project folder its paths must stay within
+type BuiltinDir struct {
+	Root, Dir string
+}
+
+// Builtins are the portable actions selected with the builtin key
+var Builtins = map[string]Builtin{
+	"copy":    builtinCopy,
+	"move":    builtinMove,
+	"remove":  builtinRemove,
+	"chmod":   builtinChmod,
+	"mkdir":   builtinMkdir,
+	"symlink": builtinSymlink,
+}
+
+// BuiltinNames sorted for messages
+func BuiltinNames() []string {
+	names := []string{}
+	for name := range Builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunBuiltin runs the named builtin action in a folder of the project
+func RunBuiltin(name string, bd BuiltinDir, args []string) error {
+	builtin, ok := Builtins[name]
+	if !ok {
+		return fmt.Errorf("builtin must be one of: %s", BuiltinNames())
+	}
+	if err := builtin(bd, args); err != nil {
+		return fmt.Errorf("builtin %s: %s", name, err)
+	}
+	return nil
+}
+
+// Path of a builtin argument, an error when it is outside the project folder.
+// The path is followed through symbolic links, its last element included, so a link cannot lead outside.
+func (bd BuiltinDir) Path(name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(bd.Dir, name)
+	}
+	path = filepath.Clean(path)
+	if !insideProject(bd.Root, path) {
+		return "", fmt.Errorf("%s is outside the project", name)
+	}
+	root, err := filepath.EvalSymlinks(bd.Root)
+	if err != nil {
+		return "", err
+	}
+	real, err := evalExisting(path)
+	if err != nil {
+		return "", err
+	}
+	if !insideProject(root, real) {
+		return "", fmt.Errorf("%s is outside the project", name)
+	}
+	return path, nil
+}
+
+// evalExisting follows the symbolic links of the part of a path that exists,
+// and a dangling link to where its target would be made
+func evalExisting(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	real, err = evalExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Join(real, filepath.Base(path))
+	if target, err := os.Readlink(path); err == nil {
+		return evalExisting(resolve(real, target))
+	}
+	return path, nil
+}
+
+// resolve a name relative to dir
+func resolve(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+func exactArgs(args []string, count int, usage string) error {
+	if len(args) != count {
+		return fmt.Errorf("usage: %s", usage)
+	}
+	return nil
+}
+
+// paths of builtin args within the project
+func (bd BuiltinDir) paths(names []string) ([]string, error) {
+	paths := []string{}
+	for _, name := range names {
+		path, err := bd.Path(name)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func builtinCopy(bd BuiltinDir, args []string) error {
+	if err := exactArgs(args, 2, "copy source destination"); err != nil {
+		return err
+	}
+	paths, err := bd.paths(args)
+	if err != nil {
+		return err
+	}
+	src, dst := paths[0], paths[1]
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		// links within the tree copied are followed, so they are kept in the project too
+		for _, name := range []string{path, target} {
+			if _, err := bd.Path(name); err != nil {
+				return err
+			}
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func builtinMove(bd BuiltinDir, args []string) error {
+	if err := exactArgs(args, 2, "move source destination"); err != nil {
+		return err
+	}
+	paths, err := bd.paths(args)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(paths[1]), os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(paths[0], paths[1])
+}
+
+func builtinRemove(bd BuiltinDir, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: remove path...")
+	}
+	paths, err := bd.paths(args)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if path == filepath.Clean(bd.Root) {
+			return fmt.Errorf("cannot remove the project folder")
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func builtinMkdir(bd BuiltinDir, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mkdir path...")
+	}
+	paths, err := bd.paths(args)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.MkdirAll(path, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func builtinSymlink(bd BuiltinDir, args []string) error {
+	if err := exactArgs(args, 2, "symlink target link"); err != nil {
+		return err
+	}
+	link, err := bd.Path(args[1])
+	if err != nil {
+		return err
+	}
+	// the target is kept as written, but must point within the project
+	target := args[0]
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(link), target)
+	}
+	if _, err := bd.Path(target); err != nil {
+		return fmt.Errorf("target %s is outside the project", args[0])
+	}
+	if err := os.MkdirAll(filepath.Dir(link), os.ModePerm); err != nil {
+		return err
+	}
+	// regenerating replaces a link made by an earlier run
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	}
+	// the target is kept as written, so relative links stay relative
+	return os.Symlink(args[0], link)
+}
+
+func builtinChmod(bd BuiltinDir, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: chmod mode path...")
+	}
+	paths, err := bd.paths(args[1:])
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		mode, err := ChmodMode(args[0], info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChmodMode applies an octal mode like 755 or a symbolic mode like +x or go-w to a file mode
+func ChmodMode(spec string, mode os.FileMode) (os.FileMode, error) {
+	if octal, err := strconv.ParseUint(spec, 8, 32); err == nil {
+		return os.FileMode(octal).Perm(), nil
+	}
+	for _, clause := range strings.Split(spec, ",") {
+		op := strings.IndexAny(clause, "+-=")
+		if op < 0 {
+			return 0, fmt.Errorf("invalid mode %q", spec)
+		}
+		who := clause[:op]
+		if who == "" {
+			who = "a"
+		}
+		var mask os.FileMode
+		for _, w := range who {
+			switch w {
+			case 'u':
+				mask |= 0700
+			case 'g':
+				mask |= 0070
+			case 'o':
+				mask |= 0007
+			case 'a':
+				mask |= 0777
+			default:
+				return 0, fmt.Errorf("invalid mode %q", spec)
+			}
+		}
+		var bits os.FileMode
+		for _, p := range clause[op+1:] {
+			switch p {
+			case 'r':
+				bits |= 0444
+			case 'w':
+				bits |= 0222
+			case 'x':
+				bits |= 0111
+			default:
+				return 0, fmt.Errorf("invalid mode %q", spec)
+			}
+		}
+		bits &= mask
+		switch clause[op] {
+		case '+':
+			mode |= bits
+		case '-':
+			mode &^= bits
+		case '=':
+			mode = mode&^mask | bits
+		}
+	}
+	return mode, nil
+}
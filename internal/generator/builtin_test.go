@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChmodMode(t *testing.T) {
+	tests := []struct {
+		spec string
+		mode os.FileMode
+		want os.FileMode
+		err  bool
+	}{
+		{"755", 0600, 0755, false},
+		{"0644", 0777, 0644, false},
+		{"+x", 0644, 0755, false},
+		{"a+x", 0644, 0755, false},
+		{"u+x", 0644, 0744, false},
+		{"go-w", 0666, 0644, false},
+		{"o-rwx", 0777, 0770, false},
+		{"u=rw", 0755, 0655, false},
+		{"a=", 0755, 0, false},
+		{"u+x,g+x", 0644, 0754, false},
+		{"ug=rx,o=", 0777, 0550, false},
+		{"x", 0644, 0, true},
+		{"z+x", 0644, 0, true},
+		{"+q", 0644, 0, true},
+		{"u+x,", 0644, 0, true},
+		{"", 0644, 0, true},
+		{"999", 0644, 0, true},
+	}
+	for _, test := range tests {
+		got, err := ChmodMode(test.spec, test.mode)
+		if test.err {
+			if err == nil {
+				t.Errorf("ChmodMode(%q, %o) = %o, want an error", test.spec, test.mode, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ChmodMode(%q, %o): %s", test.spec, test.mode, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ChmodMode(%q, %o) = %o, want %o", test.spec, test.mode, got, test.want)
+		}
+	}
+}
+
+func TestBuiltinPath(t *testing.T) {
+	top := t.TempDir()
+	root := filepath.Join(top, "project")
+	if err := os.MkdirAll(filepath.Join(root, "web"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(top, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(top, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("web", filepath.Join(root, "site")); err != nil {
+		t.Fatal(err)
+	}
+	bd := BuiltinDir{Root: root, Dir: filepath.Join(root, "web")}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"index.html", "web/index.html"},
+		{"../go.mod", "go.mod"},
+		{"new/folder/file", "web/new/folder/file"},
+		{filepath.Join(root, "abs.txt"), "abs.txt"},
+		{"..", "."},
+		{"../..", ""},
+		{"../../other", ""},
+		{"/etc/passwd", ""},
+		{"../escape/x", ""},
+		{"../escape", ""},
+		{"../dangling", ""},
+		{"../site", "site"},
+		{"../site/index.html", "site/index.html"},
+	}
+	for _, test := range tests {
+		path, err := bd.Path(test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s resolved to %s outside the project", test.name, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if want := filepath.Join(root, test.want); path != want {
+			t.Errorf("%s resolved to %s, want %s", test.name, path, want)
+		}
+	}
+}
+
+func TestBuiltinsStayInProject(t *testing.T) {
+	top := t.TempDir()
+	root := filepath.Join(top, "project")
+	if err := writeTestFile(top, "outside.txt", "keep"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(root, "a.txt", "a"); err != nil {
+		t.Fatal(err)
+	}
+	// links in the project leading to a file outside it, and to where one would be made
+	if err := os.Symlink(filepath.Join(top, "outside.txt"), filepath.Join(root, "out.lnk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(top, "made.txt"), filepath.Join(root, "new.lnk")); err != nil {
+		t.Fatal(err)
+	}
+	bd := BuiltinDir{Root: root, Dir: root}
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"chmod", []string{"+x", "out.lnk"}, "outside the project"},
+		{"copy", []string{"a.txt", "out.lnk"}, "outside the project"},
+		{"copy", []string{"a.txt", "new.lnk"}, "outside the project"},
+		{"copy", []string{"out.lnk", "b.txt"}, "outside the project"},
+		{"copy", []string{".", "sub/tree"}, "outside the project"},
+		{"remove", []string{"../outside.txt"}, "outside the project"},
+		{"remove", []string{"."}, "cannot remove the project folder"},
+		{"remove", []string{filepath.Join(top, "outside.txt")}, "outside the project"},
+		{"copy", []string{"../outside.txt", "b.txt"}, "outside the project"},
+		{"copy", []string{"a.txt", "../b.txt"}, "outside the project"},
+		{"move", []string{"a.txt", "../../b.txt"}, "outside the project"},
+		{"chmod", []string{"+x", "../outside.txt"}, "outside the project"},
+		{"mkdir", []string{"../made"}, "outside the project"},
+		{"symlink", []string{"../outside.txt", "link"}, "target ../outside.txt is outside the project"},
+		{"symlink", []string{"a.txt", "../link"}, "outside the project"},
+		{"copy", []string{"a.txt", "sub/b.txt"}, ""},
+		{"symlink", []string{"../a.txt", "sub/link"}, ""},
+		{"chmod", []string{"go-r", "sub/b.txt"}, ""},
+		{"remove", []string{"sub"}, ""},
+	}
+	for _, test := range tests {
+		err := RunBuiltin(test.name, bd, test.args)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s %s: %s", test.name, test.args, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s %s: got error %v, want %s", test.name, test.args, err, test.err)
+		}
+	}
+	for _, link := range []string{"out.lnk", "new.lnk"} {
+		if err := os.Remove(filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(top, "outside.txt")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("outside file changed: %v %v", info, err)
+	}
+	if got := readTree(t, top); got != "outside.txt=keep project/a.txt=a" {
+		t.Errorf("files after builtins: %s", got)
+	}
+}
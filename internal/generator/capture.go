@@ -0,0 +1,84 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/robbyriverside/brief"
+)
+
+// capturedUse finds the names given to the captured template function
+var capturedUse = regexp.MustCompile("captured\\s+[\"`]([^\"`]+)[\"`]")
+
+// Captures holds the trimmed output of actions with a capture key for the rest of a project.
+// A nil Captures stands in for plan and validate, which show placeholders.
+type Captures struct {
+	// Placeholders stand in for values that were not captured
+	Placeholders bool
+	mu           sync.Mutex
+	values       map[string]string
+}
+
+// NewCaptures for a run that executes actions
+func NewCaptures() *Captures {
+	return &Captures{values: map[string]string{}}
+}
+
+// Set a captured value
+func (c *Captures) Set(name, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[name] = value
+}
+
+// Placeholder stands in for a value that was not captured
+func Placeholder(name string) string {
+	return fmt.Sprintf("<%s>", name)
+}
+
+// Captured is the captured template function.
+// Without captures a placeholder is returned so that templates can still be expanded.
+func (c *Captures) Captured(name string) (string, error) {
+	if c == nil {
+		return Placeholder(name), nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[name]
+	if !ok && c.Placeholders {
+		return Placeholder(name), nil
+	}
+	if !ok {
+		return "", fmt.Errorf("nothing captured as %s, its action must run first", name)
+	}
+	return value, nil
+}
+
+// ActionCaptures names the captured values an action's keys, env and script use
+func ActionCaptures(action *brief.Node) []string {
+	texts := []string{action.Content}
+	for key, value := range action.Keys {
+		if key != "capture" {
+			texts = append(texts, value)
+		}
+	}
+	if env := action.Child("env"); env != nil {
+		for _, value := range env.Keys {
+			texts = append(texts, value)
+		}
+	}
+	used := map[string]bool{}
+	for _, text := range texts {
+		for _, match := range capturedUse.FindAllStringSubmatch(text, -1) {
+			used[match[1]] = true
+		}
+	}
+	names := []string{}
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestCaptured(t *testing.T) {
+	placeholders := NewCaptures()
+	placeholders.Placeholders = true
+	set := NewCaptures()
+	set.Set("v", "1.2")
+	tests := []struct {
+		name     string
+		captures *Captures
+		want     string
+		err      bool
+	}{
+		{"plan", nil, "<v>", false},
+		{"diff", placeholders, "<v>", false},
+		{"render", NewCaptures(), "", true},
+		{"generate", set, "1.2", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.captures.Captured("v")
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSkippedCapture(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *ExecPolicy
+		input  string
+		want   string
+	}{
+		{"runs", &ExecPolicy{}, "", "1.2"},
+		{"no exec", &ExecPolicy{NoExec: true}, "", "<v>"},
+		{"declined", &ExecPolicy{Confirm: true}, "n\n", "<v>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gtor := (&Command{}).New()
+			gtor.Captures = NewCaptures()
+			gtor.Policy = test.policy
+			gtor.Policy.in = bufio.NewReader(strings.NewReader(test.input))
+			gtor.Policy.out = &bytes.Buffer{}
+			spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+			action := testAction("version", "exec", "echo 1.2", "capture", "v")
+			if err := gtor.ExecAction(context.Background(), action, spec, t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := gtor.Captures.Captured("v")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package generator
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"time"
 
 	"github.com/robbyriverside/brevity/internal/brevity"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/robbyriverside/brief"
+	"github.com/sirupsen/logrus"
 )
 
 /*
@@ -28,31 +32,92 @@ type Command struct {
 	} `positional-args:"true" required:"true"`
 	Library string `short:"l" long:"lib" description:"Brevity library location" env:"BREVITY_LIB"`
 	Render  bool   `short:"r" long:"render" description:"Render files without actions"`
-	specDir string
+
+	ActionTimeout time.Duration `long:"action-timeout" description:"Default timeout for each action, like 5m"`
+	Shell         string        `long:"shell" description:"Shell for shell actions, like \"bash -c\" (default sh -c)" env:"BREVITY_SHELL"`
+	Jobs          int           `short:"J" long:"jobs" default:"1" description:"Run up to N independent actions at once"`
+
+	AllowExec []string `long:"allow-exec" description:"Executable actions may run, repeat for each" env:"BREVITY_ALLOW_EXEC" env-delim:","`
+	Confirm   bool     `long:"confirm" description:"Confirm each command before it runs"`
+	NoExec    bool     `long:"no-exec" description:"Skip actions that execute commands"`
+	KeepGoing bool     `short:"k" long:"keep-going" description:"Generate the other projects when one fails"`
+
+	specDir   string
+	plan      *Plan
+	diff      *TreeDiff
+	runLog    *RunLog
+	captures  *Captures
+	policy    *ExecPolicy
+	summary   *Summary
+	preflight *Preflight
+	finalize  []*Schedule
+	// chain of specs being generated by generate actions
+	chain      *FileSet
+	chainFiles []string
+	// state of the project being generated
+	projectPlan *ProjectPlan
+	manifest    *Manifest
+	state       *State
+	stage       *Staging
 }
 
 // Execute the project command
 func (cmd *Command) Execute(args []string) error {
-	specfile, err := filepath.Abs(cmd.Args.SpecFile)
-	if err != nil {
-		return err
-	}
-	cmd.specDir = filepath.Dir(specfile)
 	node, err := cmd.ReadSpec()
 	if err != nil {
 		return err
 	}
-	return cmd.Generate(node)
+	ctx, stop := InterruptContext()
+	defer stop()
+	return cmd.Generate(ctx, node)
+}
+
+// SpecOptions of the commands that read a spec without generating it
+type SpecOptions struct {
+	Library string `short:"l" long:"lib" description:"Brevity library location" env:"BREVITY_LIB"`
+}
+
+// ReadSpec from specfile into cmd, which generates into destination using these options
+func (opts *SpecOptions) ReadSpec(cmd *Command, specfile, destination string) (*brief.Node, error) {
+	cmd.Library = opts.Library
+	cmd.Args.SpecFile = specfile
+	cmd.Args.Destination = destination
+	return cmd.ReadSpec()
+}
+
+// InterruptContext is cancelled by Ctrl-C
+func InterruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
 }
 
 // AddCommand to the parser
 func AddCommand(parser *flags.Parser) error {
-	_, err := parser.AddCommand("generate",
-		"generate brevity projects",
-		"creates files and folders for brevity projects",
-		&Command{},
-	)
-	return err
+	commands := []struct {
+		name, short, long string
+		data              interface{}
+	}{
+		{"generate", "generate brevity projects",
+			"creates files and folders for brevity projects",
+			&Command{}},
+		{"validate", "validate brevity spec",
+			"checks a brevity spec against its generators without writing files or running actions",
+			&ValidateCommand{}},
+		{"plan", "plan brevity generation",
+			"lists every file and action a generate would produce, with resolved paths, without writing anything",
+			&PlanCommand{}},
+		{"diff", "diff brevity projects",
+			"shows unified diffs between freshly generated files and an existing destination, without running actions",
+			&DiffCommand{}},
+		{"expand", "expand brevity spec",
+			"prints the brevity spec after macro expansion and merging",
+			&ExpandCommand{}},
+	}
+	for _, c := range commands {
+		if _, err := parser.AddCommand(c.name, c.short, c.long, c.data); err != nil {
+			return err
+		}
+	}
+	return AddLibCommand(parser)
 }
 
 // ReadNode reads a single node from a brief file
@@ -76,6 +141,11 @@ func ReadNode(specfile string) (*brief.Node, error) {
 
 // ReadSpec brevity spec from file
 func (cmd *Command) ReadSpec() (*brief.Node, error) {
+	specfile, err := filepath.Abs(cmd.Args.SpecFile)
+	if err != nil {
+		return nil, err
+	}
+	cmd.specDir = filepath.Dir(specfile)
 	spec, err := ReadNode(cmd.Args.SpecFile)
 	if err != nil {
 		return nil, err
@@ -92,21 +162,94 @@ func (cmd *Command) ReadSpec() (*brief.Node, error) {
 }
 
 // Generate brevity projects into a destination folder
-func (cmd *Command) Generate(brevity *brief.Node) error {
+func (cmd *Command) Generate(ctx context.Context, brevity *brief.Node) error {
 	path, err := filepath.Abs(cmd.Args.Destination)
 	if err != nil {
 		return fmt.Errorf("expanding destination %s failed: %s", cmd.Args.Destination, err)
 	}
 	cmd.Args.Destination = path
-	if err := ValidateFolder(path); err != nil {
-		return err
+	if cmd.plan == nil {
+		if err := ValidateFolder(path, cmd.diff == nil); err != nil {
+			return err
+		}
+	}
+	// nested generations share the run log of the run that started them
+	if cmd.plan == nil && cmd.diff == nil && !cmd.Render && cmd.runLog == nil {
+		cmd.runLog, err = OpenRunLog(path)
+		if err != nil {
+			return err
+		}
+		defer cmd.runLog.Close()
+		cmd.policy = NewExecPolicy()
+		cmd.policy.NoExec = cmd.NoExec
+		cmd.policy.Allow = cmd.AllowExec
+		cmd.policy.Confirm = cmd.Confirm
+		if err := cmd.policy.ReadLibraryPolicy(cmd.Library); err != nil {
+			return err
+		}
+	}
+	// nested generations extend the chain of the run that started them
+	if cmd.plan == nil && cmd.diff == nil && !cmd.Render && cmd.chain == nil {
+		if err := cmd.StartChain(); err != nil {
+			return err
+		}
+	}
+	// nested generations add to the summary of the run that started them
+	if cmd.summary == nil {
+		cmd.summary = &Summary{}
+		defer cmd.summary.Print(os.Stdout)
 	}
 	// Generate code for each project
+	failed := 0
 	for _, project := range brevity.Body {
 		if len(project.Name) == 0 {
 			return fmt.Errorf("invalid brevity spec: project must be named")
 		}
-		if err := cmd.Project(project); err != nil {
+		err := cmd.Project(ctx, project)
+		if err == nil {
+			continue
+		}
+		if !cmd.KeepGoing || ctx.Err() != nil {
+			return err
+		}
+		logrus.WithError(err).WithField("project", project.Name).Error("project failed, keep going")
+		cmd.summary.Add(fmt.Sprintf("project %s", project.Name), "keep-going", err)
+		failed++
+	}
+	if err := cmd.Finalize(ctx); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed", failed, len(brevity.Body))
+	}
+	return nil
+}
+
+// Finalize runs the finalize actions of each project once every project is generated.
+// Those projects are already in place, so a failure is recorded but not rolled back.
+func (cmd *Command) Finalize(ctx context.Context) error {
+	for _, schedule := range cmd.finalize {
+		err := schedule.Run(ctx, PhaseFinalize)
+		if schedule.Manifest == nil {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			status := "failed"
+			if ctx.Err() != nil {
+				status = "interrupted"
+			}
+			if werr := schedule.Manifest.WriteFailed(status, err); werr != nil {
+				logrus.WithError(werr).WithField("project", schedule.Project).Error("recording failed generation")
+			}
+			return err
+		}
+		if err := schedule.State.Write(); err != nil {
+			return err
+		}
+		if err := schedule.Manifest.Write(); err != nil {
 			return err
 		}
 	}
@@ -115,7 +258,7 @@ func (cmd *Command) Generate(brevity *brief.Node) error {
 
 // CompileSection within a project
 // Gather
-func (cmd *Command) CompileSection(section *brief.Node) (*Generator, error) {
+func (cmd *Command) CompileSection(ctx context.Context, section *brief.Node) (*Generator, error) {
 	genfile := filepath.Join(cmd.Library, section.Type, "generator.brief")
 
 	gtor := cmd.New()
@@ -139,20 +282,16 @@ func (cmd *Command) CompileSection(section *brief.Node) (*Generator, error) {
 		brevity.Debug("compile section", section.Type, "unnamed from:", genfile)
 	}
 
-	if err := gtor.LoadGenerator(genfile); err != nil {
-		return nil, err
-	}
-
+	name := ""
 	if variation {
-		subgenfile := filepath.Join(cmd.Library, section.Type, fmt.Sprintf("%s.brief", section.Name))
-		if _, err := os.Stat(subgenfile); !os.IsNotExist(err) {
-			if err := gtor.LoadGenerator(subgenfile); err != nil {
-				return nil, err
-			}
-		}
+		name = section.Name
 	}
-	if len(gtor.Catalog) == 0 {
-		return nil, fmt.Errorf("empty generator catalog")
+	if err := gtor.LoadSectionGenerators(section.Type, name); err != nil {
+		return nil, err
+	}
+	// a missing tool is reported before any file is written
+	if err := gtor.CheckRequires(ctx); err != nil {
+		return nil, err
 	}
 
 	brevity.Debug("section catalog size", len(gtor.Catalog))
@@ -168,32 +307,196 @@ func (cmd *Command) CompileSection(section *brief.Node) (*Generator, error) {
 }
 
 // Project generates nth project in the spec
-func (cmd *Command) Project(project *brief.Node) error {
+func (cmd *Command) Project(ctx context.Context, project *brief.Node) error {
 	if project.Name == "" {
 		return fmt.Errorf("project name is required")
 	}
 	dir := filepath.Join(cmd.Args.Destination, project.Name)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+	state, err := ReadState(dir)
+	if err != nil {
 		return err
 	}
+	cmd.state = state
+	// values are captured for the project whose actions capture them, and plans show placeholders
+	cmd.captures = nil
+	if cmd.plan == nil {
+		cmd.captures = NewCaptures()
+		// diff runs no actions, so its templates are compared with placeholders
+		cmd.captures.Placeholders = cmd.diff != nil
+	}
+	if cmd.plan != nil {
+		cmd.projectPlan = cmd.plan.AddProject(project.Name, dir)
+		schedule, err := cmd.CompileProject(ctx, project, dir)
+		if err != nil {
+			return err
+		}
+		if err := schedule.Run(ctx, PhasePreRender); err != nil {
+			return err
+		}
+		if err := cmd.RenderSections(ctx, project, schedule, dir); err != nil {
+			return err
+		}
+		if err := schedule.Run(ctx, PhasePostRender); err != nil {
+			return err
+		}
+		if err := schedule.Run(ctx, PhasePostProject); err != nil {
+			return err
+		}
+		cmd.finalize = append(cmd.finalize, schedule)
+		return nil
+	}
 	if brevity.Options.Verbose {
 		fmt.Println("--> project", project.Name, dir)
 	}
-	if err := os.Chdir(dir); err != nil {
+	previous, err := ReadManifest(dir)
+	if err != nil {
 		return err
 	}
-	if err := cmd.ExpandProjectMacros(project); err != nil {
+	failed, err := ReadFailedManifest(dir)
+	if err != nil {
 		return err
 	}
-	for _, section := range project.Body {
-		gtor, err := cmd.CompileSection(section)
-		if err != nil {
+	if failed != nil {
+		logrus.WithFields(logrus.Fields{
+			"project": project.Name,
+			"status":  failed.Status,
+			"error":   failed.Error,
+		}).Warn("previous generation did not complete")
+	}
+	cmd.manifest = NewManifest(project.Name, dir)
+	cmd.manifest.Spec = filepath.Join(cmd.specDir, filepath.Base(cmd.Args.SpecFile))
+	cmd.manifest.Library = cmd.Library
+	cmd.manifest.SetPrevious(previous)
+	if cmd.runLog != nil {
+		cmd.manifest.Log = cmd.runLog.Path
+	}
+
+	newStaging := NewStaging
+	if cmd.diff != nil {
+		newStaging = NewDiffStaging
+	}
+	stage, err := newStaging(dir)
+	if err != nil {
+		return err
+	}
+	defer stage.Cleanup()
+	cmd.stage = stage
+	if cmd.diff == nil && previous != nil {
+		// files brevity generated are restored on rollback, even when an action changes them in place
+		tracked := []string{}
+		for _, file := range previous.Files {
+			tracked = append(tracked, filepath.FromSlash(file.File))
+		}
+		if err := stage.Backup(tracked); err != nil {
 			return err
 		}
+	}
 
-		if err := gtor.ValidateSection(section); err != nil {
+	schedule, err := cmd.CompileProject(ctx, project, dir)
+	if err != nil {
+		return err
+	}
+	schedule.Manifest = cmd.manifest
+	schedule.State = cmd.state
+	if err := cmd.policy.CheckSchedule(schedule); err != nil {
+		return err
+	}
+	// pre-render effects are rolled back with the rest of the project
+	prerender := cmd.diff == nil && len(schedule.Phase(PhasePreRender)) > 0
+	if prerender {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 			return err
 		}
+		if err := schedule.Run(ctx, PhasePreRender); err != nil {
+			return cmd.rollback(ctx, project, err)
+		}
+	}
+
+	// nothing is written to the destination until every template succeeds
+	if err := cmd.RenderSections(ctx, project, schedule, dir); err != nil {
+		if prerender {
+			return cmd.rollback(ctx, project, err)
+		}
+		return err
+	}
+	if cmd.diff != nil {
+		return cmd.diff.AddProject(project.Name, stage, cmd.manifest)
+	}
+	if err := stage.Commit(); err != nil {
+		return cmd.rollback(ctx, project, err)
+	}
+	if err := schedule.Run(ctx, PhasePostRender); err != nil {
+		return cmd.rollback(ctx, project, err)
+	}
+	// the project is complete once its sections and nested generations are in place
+	if err := cmd.state.Write(); err != nil {
+		return err
+	}
+	if err := cmd.manifest.Write(); err != nil {
+		return err
+	}
+	if len(schedule.Phase(PhasePostProject)) == 0 {
+		cmd.finalize = append(cmd.finalize, schedule)
+		return nil
+	}
+	// post-project actions work on the completed project, so a failure is recorded but not rolled back
+	if err := schedule.Run(ctx, PhasePostProject); err != nil {
+		return cmd.recordFailure(ctx, project, err)
+	}
+	cmd.finalize = append(cmd.finalize, schedule)
+	if err := cmd.state.Write(); err != nil {
+		return err
+	}
+	return cmd.manifest.Write()
+}
+
+// rollback the project to its state before generation, recording the failure
+func (cmd *Command) rollback(ctx context.Context, project *brief.Node, err error) error {
+	if rerr := cmd.stage.Rollback(); rerr != nil {
+		logrus.WithError(rerr).WithField("project", project.Name).Error("rollback failed")
+	}
+	return cmd.recordFailure(ctx, project, err)
+}
+
+// recordFailure of the project's generation in failed.json
+func (cmd *Command) recordFailure(ctx context.Context, project *brief.Node, err error) error {
+	status := "failed"
+	if Interrupted(ctx) {
+		status = "interrupted"
+	}
+	if werr := cmd.manifest.WriteFailed(status, err); werr != nil {
+		logrus.WithError(werr).WithField("project", project.Name).Error("recording failed generation")
+	}
+	return err
+}
+
+// CompileProject compiles each section of a project and schedules its actions
+func (cmd *Command) CompileProject(ctx context.Context, project *brief.Node, dir string) (*Schedule, error) {
+	if err := cmd.ExpandProjectMacros(project); err != nil {
+		return nil, err
+	}
+	schedule := NewSchedule(project.Name)
+	schedule.Jobs = cmd.Jobs
+	for _, section := range project.Body {
+		gtor, err := cmd.CompileSection(ctx, section)
+		if err != nil {
+			return nil, err
+		}
+		if err := gtor.ValidateSection(section); err != nil {
+			return nil, err
+		}
+		schedule.AddSection(gtor, project, section, dir)
+	}
+	if err := schedule.Order(); err != nil {
+		return nil, fmt.Errorf("project %s: %s", project.Name, err)
+	}
+	return schedule, nil
+}
+
+// RenderSections renders the templates of each compiled section of a project
+func (cmd *Command) RenderSections(ctx context.Context, project *brief.Node, schedule *Schedule, dir string) error {
+	for i, section := range project.Body {
+		gtor := schedule.Generators[i]
 		if err := gtor.ApplyTemplates(project, dir); err != nil {
 			return err
 		}
@@ -202,19 +505,10 @@ func (cmd *Command) Project(project *brief.Node) error {
 		}
 
 		for _, subnode := range section.Body {
-			if err := gtor.NextNode(subnode, dir); err != nil {
+			if err := gtor.NextNode(ctx, subnode, dir); err != nil {
 				return err
 			}
 		}
-
-		// actions as we walk back up the tree
-		// XXX: should be predictable, may also be actions on a second pass
-		if err := gtor.ApplyActions(section, dir); err != nil {
-			return err
-		}
-		if err := gtor.ApplyActions(project, dir); err != nil {
-			return err
-		}
 	}
 	return nil
 }
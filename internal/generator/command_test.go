@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
+
+func TestCommandFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		check func(command flags.Commander) bool
+	}{
+		{"plan json", []string{"plan", "-j", "spec.brief", "out"}, func(command flags.Commander) bool {
+			plan, ok := command.(*PlanCommand)
+			return ok && plan.JSON && plan.Args.Destination == "out"
+		}},
+		{"generate jobs", []string{"generate", "-J", "4", "spec.brief", "out"}, func(command flags.Commander) bool {
+			cmd, ok := command.(*Command)
+			return ok && cmd.Jobs == 4
+		}},
+		{"generate jobs default", []string{"generate", "spec.brief", "out"}, func(command flags.Commander) bool {
+			cmd, ok := command.(*Command)
+			return ok && cmd.Jobs == 1
+		}},
+		{"validate lib", []string{"validate", "-l", "lib", "spec.brief"}, func(command flags.Commander) bool {
+			validate, ok := command.(*ValidateCommand)
+			return ok && validate.Library == "lib" && validate.Args.SpecFile == "spec.brief"
+		}},
+		{"expand project", []string{"expand", "--lib", "lib", "-p", "app", "spec.brief"}, func(command flags.Commander) bool {
+			expand, ok := command.(*ExpandCommand)
+			return ok && expand.Library == "lib" && expand.Project == "app"
+		}},
+		{"diff lib", []string{"diff", "-l", "lib", "spec.brief", "out"}, func(command flags.Commander) bool {
+			diff, ok := command.(*DiffCommand)
+			return ok && diff.Library == "lib" && diff.Args.Destination == "out"
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := flags.NewParser(&struct{}{}, flags.None)
+			if err := AddCommand(parser); err != nil {
+				t.Fatal(err)
+			}
+			var active flags.Commander
+			parser.CommandHandler = func(command flags.Commander, args []string) error {
+				active = command
+				return nil
+			}
+			if _, err := parser.ParseArgs(test.args); err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(active) {
+				t.Errorf("parsed %s as %+v", test.args, active)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/robbyriverside/brief"
+)
+
+// delimsDirective on the first line of a template file sets the delimiters it is parsed with,
+// like {{/* brevity:delims [[ ]] */}} or # brevity:delims [[ ]].  The line is not part of the template.
+var delimsDirective = regexp.MustCompile(`brevity:delims\s+(\S+)\s+(\S+)`)
+
+// ParseDelims splits a delims value like "[[ ]]" into its left and right delimiters
+func ParseDelims(value string) (left, right string, err error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("delims %q must be a left and right delimiter, like \"[[ ]]\"", value)
+	}
+	return fields[0], fields[1], nil
+}
+
+// LoadSectionDelims reads the delimiters of the templates in a section's generator.brief and
+// variation brief, without compiling them, so that templates parsed to expand macros use them
+func (gtor *Generator) LoadSectionDelims(section *brief.Node) error {
+	genfiles := []string{filepath.Join(gtor.LibDir, section.Type, "generator.brief")}
+	if section.Name != "" {
+		genfiles = append(genfiles, filepath.Join(gtor.LibDir, section.Type, fmt.Sprintf("%s.brief", section.Name)))
+	}
+	for _, genfile := range genfiles {
+		if _, err := os.Stat(genfile); os.IsNotExist(err) {
+			continue
+		}
+		gen, err := ReadNode(genfile)
+		if err != nil {
+			return err
+		}
+		templates := gen.Child("templates")
+		if templates == nil {
+			continue
+		}
+		for _, tmpl := range templates.Body {
+			delims, ok := tmpl.Keys["delims"]
+			if !ok {
+				continue
+			}
+			left, right, err := ParseDelims(delims)
+			if err != nil {
+				return fmt.Errorf("%s template:%q %s", genfile, tmpl.Name, err)
+			}
+			gtor.Delims[tmpl.Name] = [2]string{left, right}
+		}
+	}
+	return nil
+}
+
+// parseTemplateFile into the template set like ParseFiles, with the delimiters
+// of its header directive or of the template node named after the file
+func (gtor *Generator) parseTemplateFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(filename)
+	text := string(data)
+	delims := gtor.Delims[strings.TrimSuffix(name, filepath.Ext(name))]
+	first := text
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		first = text[:i+1]
+	}
+	if strings.Contains(first, "brevity:delims") {
+		match := delimsDirective.FindStringSubmatch(first)
+		if match == nil {
+			return fmt.Errorf("%s: brevity:delims must name a left and right delimiter, like brevity:delims [[ ]]", filename)
+		}
+		delims = [2]string{match[1], match[2]}
+		text = text[len(first):]
+	}
+	tmpl := gtor.Template
+	if name != tmpl.Name() {
+		tmpl = tmpl.New(name)
+	}
+	_, err = tmpl.Delims(delims[0], delims[1]).Parse(text)
+	return err
+}
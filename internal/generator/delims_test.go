@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestParseDelims(t *testing.T) {
+	tests := []struct {
+		value       string
+		left, right string
+		err         bool
+	}{
+		{"[[ ]]", "[[", "]]", false},
+		{"  <%   %> ", "<%", "%>", false},
+		{"", "", "", true},
+		{"[[", "", "", true},
+		{"[[]]", "", "", true},
+		{"[[ ]] <<", "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			left, right, err := ParseDelims(test.value)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if left != test.left || right != test.right {
+				t.Errorf("got %q %q, want %q %q", left, right, test.left, test.right)
+			}
+		})
+	}
+}
+
+func TestParseTemplateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		delims  string
+		want    string
+		err     bool
+	}{
+		{"plain", "name: {{ .Name }}", "", "name: api", false},
+		{"directive", "{{/* brevity:delims [[ ]] */}}\non: [[ .Name ]] ${{ github.ref }}", "", "on: api ${{ github.ref }}", false},
+		{"shell directive", "# brevity:delims <% %>\nrun: <% .Name %> {{ x }}", "", "run: api {{ x }}", false},
+		{"node delims", "run: <% .Name %> {{ x }}", "<% %>", "run: api {{ x }}", false},
+		{"directive over node", "# brevity:delims [[ ]]\n[[ .Name ]] <% x %>", "<% %>", "api <% x %>", false},
+		{"directive without delimiters", "# brevity:delims\n{{ .Name }}", "", "", true},
+		{"directive with one delimiter", "# brevity:delims [[\n{{ .Name }}", "", "", true},
+		{"unclosed with other delims", "# brevity:delims [[ ]]\n[[ .Name ", "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := writeTestFile(dir, "file.tmpl", test.content); err != nil {
+				t.Fatal(err)
+			}
+			gtor := (&Command{}).New()
+			if test.delims != "" {
+				left, right, err := ParseDelims(test.delims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gtor.Delims["file"] = [2]string{left, right}
+			}
+			err := gtor.parseTemplateFile(filepath.Join(dir, "file.tmpl"))
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			var out strings.Builder
+			if err := gtor.Template.ExecuteTemplate(&out, "file.tmpl", &brief.Node{Name: "api"}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Errorf("got %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestExpandMacroDelims(t *testing.T) {
+	tests := []struct {
+		name   string
+		delims string
+		want   string
+		err    bool
+	}{
+		{"with delims", `delims:"[[ ]]"`, "model", false},
+		{"malformed delims", `delims:"[["`, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lib := t.TempDir()
+			genfile := "generator\n    templates\n        template:macro file:\"macro.txt\" element:api " + test.delims + "\n"
+			if err := writeTestFile(lib, "api/generator.brief", genfile); err != nil {
+				t.Fatal(err)
+			}
+			macro := `[[ define "@macro.api" ]]model:[[ .Name ]][[ end ]]`
+			if err := writeTestFile(lib, "api/templates/macro.tmpl", macro); err != nil {
+				t.Fatal(err)
+			}
+			spec, err := brief.NewDecoder(strings.NewReader("brevity\n    project:p\n        api:users\n"), 4).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			project := spec[0].Body[0]
+			err = (&Command{Library: lib}).ExpandProjectMacros(project)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			types := []string{}
+			for _, node := range project.Body {
+				types = append(types, node.Type)
+			}
+			if got := strings.Join(types, " "); got != test.want {
+				t.Errorf("got sections %q, want %q", got, test.want)
+			}
+		})
+	}
+}
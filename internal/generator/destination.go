@@ -11,8 +11,8 @@ func MakeFolder(names ...string) error {
 	return os.MkdirAll(filepath.Join(names...), os.ModePerm)
 }
 
-// ValidateFolder ensures a folder exists and can be written
-func ValidateFolder(name string) error {
+// ValidateFolder ensures a folder exists, and can be written when write is set
+func ValidateFolder(name string, write bool) error {
 	info, err := os.Stat(name)
 	if os.IsNotExist(err) {
 		return fmt.Errorf("destination %s not found", name)
@@ -20,7 +20,7 @@ func ValidateFolder(name string) error {
 	if !info.IsDir() {
 		return fmt.Errorf("destination %s is not a folder", name)
 	}
-	if info.Mode().Perm()&(1<<(uint(7))) == 0 {
+	if write && info.Mode().Perm()&(1<<(uint(7))) == 0 {
 		return fmt.Errorf("destination %s not writable", name)
 	}
 	return nil
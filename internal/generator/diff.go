@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DiffCommand compares freshly generated output with an existing destination
+type DiffCommand struct {
+	Args struct {
+		SpecFile    string `positional-arg-name:"specfile" description:"brevity specification file"`
+		Destination string `positional-arg-name:"destination" description:"project root folder to compare against"`
+	} `positional-args:"true" required:"true"`
+	SpecOptions
+}
+
+// Execute the diff command
+func (dc *DiffCommand) Execute(args []string) error {
+	cmd := &Command{diff: NewTreeDiff()}
+	spec, err := dc.ReadSpec(cmd, dc.Args.SpecFile, dc.Args.Destination)
+	if err != nil {
+		return err
+	}
+	ctx, stop := InterruptContext()
+	defer stop()
+	if err := cmd.Generate(ctx, spec); err != nil {
+		return err
+	}
+	cmd.diff.Print()
+	return nil
+}
+
+// TreeDiff compares generated files against a destination
+type TreeDiff struct {
+	Added     []string
+	Removed   []string
+	Changed   []string
+	Unchanged []string
+	Diffs     map[string]string
+}
+
+// NewTreeDiff constructor
+func NewTreeDiff() *TreeDiff {
+	return &TreeDiff{Diffs: make(map[string]string)}
+}
+
+// AddProject compares the staged files of a project with its folder.
+// Files the previous manifest recorded that are no longer generated are removed.
+func (tree *TreeDiff) AddProject(name string, stage *Staging, manifest *Manifest) error {
+	staged, err := listTree(stage.filesDir())
+	if err != nil {
+		return err
+	}
+	for rel := range staged {
+		label := filepath.ToSlash(filepath.Join(name, rel))
+		older, err := ioutil.ReadFile(filepath.Join(stage.Dir, rel))
+		if os.IsNotExist(err) {
+			tree.Added = append(tree.Added, label)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		newer, err := ioutil.ReadFile(filepath.Join(stage.filesDir(), rel))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(newer, older) {
+			tree.Unchanged = append(tree.Unchanged, label)
+			continue
+		}
+		tree.Changed = append(tree.Changed, label)
+		tree.Diffs[label] = UnifiedDiff("a/"+label, "b/"+label, string(older), string(newer))
+	}
+	if manifest.previous != nil {
+		for _, file := range manifest.previous.Files {
+			if manifest.File(file.File) == nil {
+				tree.Removed = append(tree.Removed, name+"/"+file.File)
+			}
+		}
+	}
+	sort.Strings(tree.Added)
+	sort.Strings(tree.Removed)
+	sort.Strings(tree.Changed)
+	sort.Strings(tree.Unchanged)
+	return nil
+}
+
+// Print the diffs followed by the file lists
+func (tree *TreeDiff) Print() {
+	for _, name := range tree.Changed {
+		fmt.Print(tree.Diffs[name])
+	}
+	printFileList("changed", tree.Changed)
+	printFileList("added", tree.Added)
+	printFileList("removed", tree.Removed)
+	printFileList("unchanged", tree.Unchanged)
+}
+
+func printFileList(title string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, name := range files {
+		fmt.Println("   ", name)
+	}
+}
@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robbyriverside/brief"
+)
+
+// ExpandCommand prints the spec after macro expansion and merging
+type ExpandCommand struct {
+	Args struct {
+		SpecFile string `positional-arg-name:"specfile" description:"brevity specification file"`
+	} `positional-args:"true" required:"true"`
+	SpecOptions
+	Project string `short:"p" long:"project" description:"Only expand the named project"`
+}
+
+// Execute the expand command
+func (ec *ExpandCommand) Execute(args []string) error {
+	cmd := &Command{}
+	spec, err := ec.ReadSpec(cmd, ec.Args.SpecFile, "")
+	if err != nil {
+		return err
+	}
+	if err := cmd.Expand(spec, ec.Project); err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(spec.Encode())
+	return err
+}
+
+// Expand the macros of every project in the spec, or only the named project
+func (cmd *Command) Expand(spec *brief.Node, name string) error {
+	projects := []*brief.Node{}
+	for _, project := range spec.Body {
+		if name != "" && project.Name != name {
+			continue
+		}
+		if err := cmd.ExpandProjectMacros(project); err != nil {
+			return fmt.Errorf("project %s: %s", project.Name, err)
+		}
+		projects = append(projects, project)
+	}
+	if name != "" && len(projects) == 0 {
+		return fmt.Errorf("project %s not found in spec", name)
+	}
+	spec.Body = projects
+	return nil
+}
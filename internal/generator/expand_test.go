@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestExpand(t *testing.T) {
+	lib := t.TempDir()
+	genfile := "generator\n    templates\n        template:macro file:\"macro.txt\" element:api\n"
+	if err := writeTestFile(lib, "api/generator.brief", genfile); err != nil {
+		t.Fatal(err)
+	}
+	macro := `{{ define "@macro.api" }}model:{{ .Name }}{{ end }}`
+	if err := writeTestFile(lib, "api/templates/macro.tmpl", macro); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		project string
+		want    string
+		err     string
+	}{
+		{"every project", "", "a:model:users b:model:orders", ""},
+		{"one project", "b", "b:model:orders", ""},
+		{"unknown project", "c", "", "project c not found in spec"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := "brevity\n    project:a\n        api:users\n    project:b\n        api:orders\n"
+			nodes, err := brief.NewDecoder(strings.NewReader(text), 4).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec := nodes[0]
+			err = (&Command{Library: lib}).Expand(spec, test.project)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			projects := []string{}
+			for _, project := range spec.Body {
+				for _, section := range project.Body {
+					projects = append(projects, project.Name+":"+section.Type+":"+section.Name)
+				}
+			}
+			if got := strings.Join(projects, " "); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
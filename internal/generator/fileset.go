@@ -29,6 +29,16 @@ func (fset *FileSet) Add(file string) *FileSet {
 	return fset
 }
 
+// Copy of the fileset, to extend one branch of a chain
+func (fset *FileSet) Copy() *FileSet {
+	dup := NewFileSet()
+	for _, file := range fset.files {
+		dup.Add(file)
+	}
+	dup.Err = fset.Err
+	return dup
+}
+
 // ReverseFiles files found in reverse order
 func (fset *FileSet) ReverseFiles() []string {
 	res := []string{}
@@ -1,19 +1,22 @@
 package generator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/robbyriverside/brevity/internal/brevity"
 	"github.com/robbyriverside/brief"
 
 	"github.com/Masterminds/sprig"
-	"github.com/google/shlex"
 	"github.com/sirupsen/logrus"
 )
 
@@ -77,25 +80,103 @@ func (cat Catalog) Add(elem string) *Agenda {
 	return agenda
 }
 
+// NodeLabel names a spec node as Type:Name, or just Type when it has no name
+func NodeLabel(node *brief.Node) string {
+	if node.Name == "" {
+		return node.Type
+	}
+	return fmt.Sprintf("%s:%s", node.Type, node.Name)
+}
+
+// NodePath names a spec node by its Type:Name chain from the project down
+func NodePath(node *brief.Node) string {
+	parts := []string{}
+	for n := node; n != nil && n.Type != "brevity"; n = n.Parent {
+		parts = append([]string{NodeLabel(n)}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
 // Generator for code
 type Generator struct {
 	Catalog         Catalog
 	Template        *template.Template
 	Render          bool
+	Shell           string
+	ActionTimeout   time.Duration
+	Jobs            int
 	LibDir, SpecDir string
+	Plan            *ProjectPlan
+	Manifest        *Manifest
+	State           *State
+	Stage           *Staging
+	Log             *RunLog
+	Captures        *Captures
+	Policy          *ExecPolicy
+	Summary         *Summary
+	Preflight       *Preflight
+	// Requires lists the tools the section generators need
+	Requires []*brief.Node
+	// Generate runs a nested generation for generate actions
+	Generate func(ctx context.Context, specfile, destination string) error
+	// Sources maps template and action nodes to the generator file defining them
+	Sources map[*brief.Node]string
+	// TemplateFiles maps template names to the file defining them
+	TemplateFiles map[string]string
+	// Delims maps template names to the delimiters their template file is parsed with
+	Delims map[string][2]string
 }
 
 // New Generator ctor
 func (cmd *Command) New() *Generator {
-	return &Generator{
-		Catalog:  Catalog{},
-		Template: template.New("top").Funcs(sprig.GenericFuncMap()),
-		Render:   cmd.Render,
-		LibDir:   cmd.Library,
-		SpecDir:  cmd.specDir,
+	if cmd.preflight == nil {
+		cmd.preflight = NewPreflight()
+	}
+	gtor := &Generator{
+		Catalog:   Catalog{},
+		Render:    cmd.Render,
+		Shell:     cmd.Shell,
+		LibDir:    cmd.Library,
+		SpecDir:   cmd.specDir,
+		Plan:      cmd.projectPlan,
+		Manifest:  cmd.manifest,
+		State:     cmd.state,
+		Stage:     cmd.stage,
+		Log:       cmd.runLog,
+		Captures:  cmd.captures,
+		Policy:    cmd.policy,
+		Summary:   cmd.summary,
+		Preflight: cmd.preflight,
+		Generate:  cmd.Nested,
+
+		ActionTimeout: cmd.ActionTimeout,
+		Jobs:          cmd.Jobs,
+		Sources:       make(map[*brief.Node]string),
+		TemplateFiles: make(map[string]string),
+		Delims:        make(map[string][2]string),
+	}
+	gtor.Template = template.New("top").Funcs(sprig.GenericFuncMap()).Funcs(gtor.FuncMap())
+	return gtor
+}
+
+// FuncMap of brevity functions for templates and value templates
+func (gtor *Generator) FuncMap() template.FuncMap {
+	funcs := template.FuncMap{
+		"captured": gtor.Captures.Captured,
+	}
+	for name, fn := range NamingFuncs {
+		funcs[name] = fn
 	}
+	return funcs
 }
 
+// Overwrite policies for templates whose file already exists
+const (
+	OverwriteAlways      = "always"
+	OverwriteNever       = "never"
+	OverwriteIfUnchanged = "if-unchanged"
+)
+
 // ValidateTemplate ensure correct template node
 func ValidateTemplate(tmpl *brief.Node, pos int) error {
 	if len(tmpl.Name) == 0 {
@@ -109,6 +190,18 @@ func ValidateTemplate(tmpl *brief.Node, pos int) error {
 	if !ok {
 		return fmt.Errorf("missing template:%q file keyword", tmpl.Name)
 	}
+	if delims, ok := tmpl.Keys["delims"]; ok {
+		if _, _, err := ParseDelims(delims); err != nil {
+			return fmt.Errorf("template:%q %s", tmpl.Name, err)
+		}
+	}
+	if overwrite, ok := tmpl.Keys["overwrite"]; ok {
+		switch overwrite {
+		case OverwriteAlways, OverwriteNever, OverwriteIfUnchanged:
+		default:
+			return fmt.Errorf("template:%q overwrite must be one of: %s %s %s", tmpl.Name, OverwriteAlways, OverwriteNever, OverwriteIfUnchanged)
+		}
+	}
 	return nil
 }
 
@@ -121,14 +214,72 @@ func ValidateAction(act *brief.Node, pos int) error {
 	if !ok {
 		return fmt.Errorf("missing action:%q element keyword", act.Name)
 	}
-	_, ok = act.Keys["exec"]
-	if !ok {
+	_, exec := act.Keys["exec"]
+	builtin, isBuiltin := act.Keys["builtin"]
+	_, generate := act.Keys["generate"]
+	if _, into := act.Keys["into"]; into && !generate {
+		return fmt.Errorf("action:%q into requires generate", act.Name)
+	}
+	switch {
+	case generate:
+		_, capture := act.Keys["capture"]
+		_, shell := act.Keys["shell"]
+		if exec || isBuiltin || act.Content != "" || capture || shell {
+			return fmt.Errorf("action:%q generate cannot exec, run a builtin, script, shell or capture", act.Name)
+		}
+	case isBuiltin:
+		if _, ok := Builtins[builtin]; !ok {
+			return fmt.Errorf("action:%q builtin must be one of: %s", act.Name, BuiltinNames())
+		}
+		if exec || act.Content != "" {
+			return fmt.Errorf("action:%q builtin takes args, not exec or a script", act.Name)
+		}
+		if _, ok := act.Keys["shell"]; ok {
+			return fmt.Errorf("action:%q builtin cannot run in a shell", act.Name)
+		}
+	case !exec && act.Content == "":
 		return fmt.Errorf("missing action:%q exec keyword", act.Name)
+	case exec && act.Content != "":
+		return fmt.Errorf("action:%q has both exec and a script", act.Name)
+	case act.Content != "" && act.Keys["shell"] == "false":
+		return fmt.Errorf("action:%q script requires a shell", act.Name)
+	}
+	if shell, ok := act.Keys["shell"]; ok {
+		if err := ValidShell(shell); err != nil {
+			return fmt.Errorf("action:%q %s", act.Name, err)
+		}
+	}
+	if _, err := ActionTimeout(act, 0); err != nil {
+		return err
+	}
+	_, inputs := act.Keys["inputs"]
+	if _, outputs := act.Keys["outputs"]; outputs && !inputs {
+		return fmt.Errorf("action:%q outputs require inputs", act.Name)
+	}
+	if _, ok := act.Keys["capture"]; ok {
+		if isBuiltin {
+			return fmt.Errorf("action:%q builtin has no output to capture", act.Name)
+		}
+		_, creates := act.Keys["creates"]
+		if creates || inputs {
+			return fmt.Errorf("action:%q capture must run every time, it cannot have creates or inputs", act.Name)
+		}
+	}
+	switch ActionOnError(act) {
+	case OnErrorFail, OnErrorWarn, OnErrorIgnore:
+	default:
+		return fmt.Errorf("action:%q on-error must be one of: %s %s %s", act.Name, OnErrorFail, OnErrorWarn, OnErrorIgnore)
+	}
+	if _, err := ActionRetries(act); err != nil {
+		return err
+	}
+	if PhaseIndex(ActionPhase(act)) < 0 {
+		return fmt.Errorf("action:%q phase must be one of: %s", act.Name, Phases)
 	}
 	return nil
 }
 
-func (gtor *Generator) compile(gen *brief.Node) error {
+func (gtor *Generator) compile(gen *brief.Node, genfile string) error {
 	templates := gen.Child("templates")
 	if templates == nil {
 		return fmt.Errorf("generator.brief missing templates node")
@@ -141,6 +292,20 @@ func (gtor *Generator) compile(gen *brief.Node) error {
 			elem := tmpl.Keys["element"]
 			agenda := gtor.Catalog.Add(elem)
 			agenda.AddTemplate(tmpl)
+			gtor.Sources[tmpl] = genfile
+			if delims, ok := tmpl.Keys["delims"]; ok {
+				left, right, _ := ParseDelims(delims)
+				gtor.Delims[tmpl.Name] = [2]string{left, right}
+			}
+		}
+	}
+	if requires := gen.Child("requires"); requires != nil {
+		for i, tool := range requires.Body {
+			if err := ValidateRequire(tool, i); err != nil {
+				return err
+			}
+			gtor.Requires = append(gtor.Requires, tool)
+			gtor.Sources[tool] = genfile
 		}
 	}
 	actions := gen.Child("actions")
@@ -155,6 +320,7 @@ func (gtor *Generator) compile(gen *brief.Node) error {
 			elem := action.Keys["element"]
 			agenda := gtor.Catalog.Add(elem)
 			agenda.AddAction(action)
+			gtor.Sources[action] = genfile
 		}
 	}
 	return nil
@@ -173,6 +339,7 @@ func (gtor *Generator) ValidateSection(section *brief.Node) error {
 		}
 	}
 	if len(missing) > 0 {
+		sort.Strings(missing)
 		return fmt.Errorf("invalid %s spec: missing elements %s", section.Type, missing)
 	}
 	return nil
@@ -194,7 +361,7 @@ func (gtor *Generator) LoadGenerator(genfile string) error {
 	if err != nil {
 		return err
 	}
-	return gtor.compile(node)
+	return gtor.compile(node, genfile)
 }
 
 // LoadGlobTemplates loads templates from the fileglob into generator
@@ -203,11 +370,22 @@ func (gtor *Generator) LoadGlobTemplates(fileglob string) error {
 	if err != nil {
 		return err
 	}
-	if len(filenames) == 0 {
-		return nil
+	for _, filename := range filenames {
+		trees := map[string]*parse.Tree{}
+		for _, tmpl := range gtor.Template.Templates() {
+			trees[tmpl.Name()] = tmpl.Tree
+		}
+		if err := gtor.parseTemplateFile(filename); err != nil {
+			return err
+		}
+		// remember which file (re)defined each template
+		for _, tmpl := range gtor.Template.Templates() {
+			if tmpl.Tree != nil && tmpl.Tree != trees[tmpl.Name()] {
+				gtor.TemplateFiles[tmpl.Name()] = filename
+			}
+		}
 	}
-	_, err = gtor.Template.ParseFiles(filenames...)
-	return err
+	return nil
 }
 
 func (gtor *Generator) loadLocalTemplates(node *brief.Node) error {
@@ -219,9 +397,35 @@ func (gtor *Generator) loadLocalTemplates(node *brief.Node) error {
 	return gtor.LoadGlobTemplates(filename)
 }
 
+// LoadSectionGenerators loads generator.brief for a section type and the variation brief when named
+func (gtor *Generator) LoadSectionGenerators(sectionType, variation string) error {
+	genfile := filepath.Join(gtor.LibDir, sectionType, "generator.brief")
+	if err := gtor.LoadGenerator(genfile); err != nil {
+		return err
+	}
+
+	if variation != "" {
+		subgenfile := filepath.Join(gtor.LibDir, sectionType, fmt.Sprintf("%s.brief", variation))
+		if _, err := os.Stat(subgenfile); !os.IsNotExist(err) {
+			if err := gtor.LoadGenerator(subgenfile); err != nil {
+				return err
+			}
+		}
+	}
+	if len(gtor.Catalog) == 0 {
+		return fmt.Errorf("empty generator catalog")
+	}
+	return nil
+}
+
 // SectionNames subdirs of the templates directory
 func (gtor *Generator) SectionNames(section *brief.Node) (map[string]bool, error) {
-	tdir := filepath.Join(gtor.LibDir, section.Type, "templates")
+	return gtor.VariationNames(section.Type)
+}
+
+// VariationNames of a section type are the subdirs of its templates directory
+func (gtor *Generator) VariationNames(sectionType string) (map[string]bool, error) {
+	tdir := filepath.Join(gtor.LibDir, sectionType, "templates")
 	files, err := ioutil.ReadDir(tdir)
 	if err != nil {
 		return nil, err
@@ -264,6 +468,12 @@ func (gtor *Generator) ApplyTemplates(spec *brief.Node, dir string) error {
 		return nil
 	}
 	for _, action := range agenda.Templates.List {
+		if gtor.Plan != nil {
+			if err := gtor.PlanFile(action, spec, dir); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := gtor.GenFile(action, spec, dir); err != nil {
 			return err
 		}
@@ -271,51 +481,60 @@ func (gtor *Generator) ApplyTemplates(spec *brief.Node, dir string) error {
 	return nil
 }
 
-// ApplyActions executes actions for this spec node
-func (gtor *Generator) ApplyActions(spec *brief.Node, dir string) error {
-	agenda, ok := gtor.Catalog[spec.Type]
-	if !ok {
-		return nil
+// RunAction executes an action on a spec node, or plans it when planning
+func (gtor *Generator) RunAction(ctx context.Context, action, spec *brief.Node, dir string) error {
+	if gtor.Plan != nil {
+		return gtor.PlanAction(action, spec, dir)
 	}
 	if gtor.Render {
 		if brevity.Options.Debug {
-			for _, action := range agenda.Actions.List {
-				fmt.Printf("*** action:%q element:%q exec:%q\n", action.Name, action.Keys["element"], action.Keys["exec"])
+			args, err := gtor.ActionArgs(action, spec)
+			if err != nil {
+				return err
 			}
+			fmt.Printf("*** action:%q element:%q exec:%q\n", action.Name, action.Keys["element"], strings.Join(args, " "))
 		}
 		return nil
 	}
-	for _, action := range agenda.Actions.List {
-		if err := gtor.ExecAction(action, spec, dir); err != nil {
-			return err
+	err := gtor.ExecAction(ctx, action, spec, dir)
+	// an interrupted run always stops
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	switch policy := ActionOnError(action); policy {
+	case OnErrorWarn, OnErrorIgnore:
+		if policy == OnErrorWarn {
+			logrus.WithError(err).WithField("action", action.Name).Warn("action failed, continuing")
 		}
+		gtor.Summary.Add(fmt.Sprintf("action %s on %s", action.Name, NodePath(spec)), policy, err)
+		return nil
 	}
-	return nil
+	return err
 }
 
-// NextNode recursively generates node hierarchy
-func (gtor *Generator) NextNode(node *brief.Node, dir string) error {
+// NextNode recursively generates files for the node hierarchy
+func (gtor *Generator) NextNode(ctx context.Context, node *brief.Node, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if err := gtor.ApplyTemplates(node, dir); err != nil {
 		return err
 	}
 
 	for _, subnode := range node.Body {
-		if err := gtor.NextNode(subnode, dir); err != nil {
+		if err := gtor.NextNode(ctx, subnode, dir); err != nil {
 			return err
 		}
 	}
-
-	// actions as we walk back up the tree
-	// no reason why, may also be actions on a second pass
-	return gtor.ApplyActions(node, dir)
+	return nil
 }
 
 // ExecValueTemplate for templates inside action key values
-func ExecValueTemplate(value string, node *brief.Node) (string, error) {
+func (gtor *Generator) ExecValueTemplate(value string, node *brief.Node) (string, error) {
 	if !strings.Contains(value, "{{") {
 		return value, nil
 	}
-	filetmpl, err := template.New("value").Funcs(sprig.GenericFuncMap()).Parse(value)
+	filetmpl, err := template.New("value").Funcs(sprig.GenericFuncMap()).Funcs(gtor.FuncMap()).Parse(value)
 	if err != nil {
 		return "", err
 	}
@@ -326,68 +545,130 @@ func ExecValueTemplate(value string, node *brief.Node) (string, error) {
 	return out.String(), nil
 }
 
-// GenFile generates a file from a template
-func (gtor *Generator) GenFile(action, spec *brief.Node, dir string) error {
+// FileName resolves the file a template generates for this spec node
+func (gtor *Generator) FileName(action, spec *brief.Node, dir string) (string, error) {
 	tmpl := gtor.Template.Lookup(action.Name)
 	if tmpl == nil {
-		return fmt.Errorf("no template found for %s", action.Name)
+		return "", fmt.Errorf("no template found for %s", action.Name)
 	}
 	filetmpl, ok := action.Keys["file"]
 	if !ok {
-		return fmt.Errorf("template %s has no file", action.Name)
+		return "", fmt.Errorf("template %s has no file", action.Name)
 	}
 
-	filename, err := ExecValueTemplate(filetmpl, spec)
+	filename, err := gtor.ExecValueTemplate(filetmpl, spec)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filename), nil
+}
+
+// GenFile generates a file from a template
+func (gtor *Generator) GenFile(action, spec *brief.Node, dir string) error {
+	filename, err := gtor.FileName(action, spec, dir)
 	if err != nil {
 		return err
 	}
-	filename = filepath.Join(dir, filename)
+	write, err := gtor.Overwrite(action, filename)
+	if err != nil || !write {
+		return err
+	}
 	if brevity.Options.Verbose {
 		fmt.Printf("template %s on %s:%s -> %s\n", action.Name, spec.Type, spec.Name, filename)
 	}
-	path := filepath.Dir(filename)
+	var content bytes.Buffer
+	if err := gtor.Template.ExecuteTemplate(&content, action.Name, spec); err != nil {
+		return err
+	}
+	output, err := gtor.KeepRegions(filename, content.Bytes())
+	if err != nil {
+		return err
+	}
+	target := filename
+	if gtor.Stage != nil {
+		target, err = gtor.Stage.Path(filename)
+		if err != nil {
+			return err
+		}
+	}
+	path := filepath.Dir(target)
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
 		return err
 	}
-	file, err := os.Create(filename)
+	file, err := os.Create(target)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	if err := gtor.Template.ExecuteTemplate(file, action.Name, spec); err != nil {
+	if _, err := file.Write(output); err != nil {
 		return err
 	}
+	if gtor.Manifest != nil {
+		gtor.Manifest.AddFile(&ManifestFile{
+			File:      gtor.Manifest.RelPath(filename),
+			Template:  action.Name,
+			Node:      NodePath(spec),
+			Hash:      ContentHash(output),
+			Generator: gtor.Sources[action],
+			Source:    gtor.TemplateFiles[action.Name],
+		})
+	}
 	return file.Sync()
 }
 
-// ExecAction executes an action
-func (gtor *Generator) ExecAction(action, spec *brief.Node, dir string) error {
-	// MAYBE: call a generator
-	exectmpl, ok := action.Keys["exec"]
-	if !ok {
-		return fmt.Errorf("template %s has no file", action.Name)
+// Overwrite decides if a template writes over an existing file using its overwrite policy
+func (gtor *Generator) Overwrite(action *brief.Node, filename string) (bool, error) {
+	policy, ok := action.Keys["overwrite"]
+	if !ok || policy == OverwriteAlways {
+		return true, nil
 	}
-
-	execute, err := ExecValueTemplate(exectmpl, spec)
-	if err != nil {
-		return err
+	existing, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return true, nil
 	}
-	args, err := shlex.Split(execute)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if brevity.Options.Verbose {
-		fmt.Printf("action %s on %s:%s exec: %s\n", action.Name, spec.Type, spec.Name, strings.Join(args, " "))
+	var previous *ManifestFile
+	if gtor.Manifest != nil {
+		// the file is still owned by brevity even when left alone
+		previous = gtor.Manifest.KeepPrevious(gtor.Manifest.RelPath(filename))
+	}
+	if policy == OverwriteNever {
+		brevity.Debug("template", action.Name, "never overwrites", filename)
+		return false, nil
+	}
+	if previous != nil && previous.Hash == ContentHash(existing) {
+		return true, nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"template": action.Name,
+		"file":     filename,
+	}).Warn("file modified since last generated, left unchanged")
+	return false, nil
+}
+
+// KeepRegions carries protected regions from an existing file into the generated content
+func (gtor *Generator) KeepRegions(filename string, content []byte) ([]byte, error) {
+	existing, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return content, nil
 	}
-	cmd := exec.Command(args[0], args[1:]...)
-	out, err := cmd.CombinedOutput()
 	if err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"output": string(out),
-			"action": action.Name,
-			"dir":    dir,
-		}).Error("failed action")
-		return err
+		return nil, err
 	}
-	return nil
+	merged, orphans, err := MergeRegions(string(content), string(existing))
+	if err != nil {
+		return nil, fmt.Errorf("file %s: %s", filename, err)
+	}
+	for _, orphan := range orphans {
+		logrus.WithFields(logrus.Fields{
+			"file":   filename,
+			"region": orphan.ID,
+		}).Warn("protected region no longer generated, contents saved in manifest")
+		if gtor.Manifest != nil {
+			gtor.Manifest.AddOrphan(gtor.Manifest.RelPath(filename), orphan)
+		}
+	}
+	return []byte(merged), nil
 }
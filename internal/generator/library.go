@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// LibCommand groups the library introspection commands
+type LibCommand struct {
+	Library string `short:"l" long:"lib" description:"Brevity library location" env:"BREVITY_LIB"`
+}
+
+// LibListCommand lists the section types in a library
+type LibListCommand struct {
+	lib *LibCommand
+}
+
+// LibDescribeCommand prints the compiled catalog of a section
+type LibDescribeCommand struct {
+	Args struct {
+		Section   string `positional-arg-name:"section" description:"section type in the library" required:"yes"`
+		Variation string `positional-arg-name:"variation" description:"section variation name"`
+	} `positional-args:"true"`
+	lib *LibCommand
+}
+
+// AddLibCommand adds lib and its subcommands to the parser
+func AddLibCommand(parser *flags.Parser) error {
+	lib := &LibCommand{}
+	libcmd, err := parser.AddCommand("lib",
+		"brevity library",
+		"describes the generators found in a brevity library",
+		lib,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = libcmd.AddCommand("list",
+		"list library sections",
+		"lists each section type in the library with its variations",
+		&LibListCommand{lib: lib},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = libcmd.AddCommand("describe",
+		"describe a library section",
+		"prints the templates and actions for each element of a section",
+		&LibDescribeCommand{lib: lib},
+	)
+	return err
+}
+
+// LibrarySections are the folders in the library with a generator.brief
+func LibrarySections(libdir string) ([]string, error) {
+	files, err := ioutil.ReadDir(libdir)
+	if err != nil {
+		return nil, err
+	}
+	sections := []string{}
+	for _, info := range files {
+		if !info.IsDir() {
+			continue
+		}
+		genfile := filepath.Join(libdir, info.Name(), "generator.brief")
+		if _, err := os.Stat(genfile); err == nil {
+			sections = append(sections, info.Name())
+		}
+	}
+	sort.Strings(sections)
+	return sections, nil
+}
+
+// Execute the lib list command
+func (lc *LibListCommand) Execute(args []string) error {
+	sections, err := LibrarySections(lc.lib.Library)
+	if err != nil {
+		return err
+	}
+	gtor := (&Command{Library: lc.lib.Library}).New()
+	for _, section := range sections {
+		names, err := gtor.VariationNames(section)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		variations := []string{}
+		for name := range names {
+			variations = append(variations, name)
+		}
+		sort.Strings(variations)
+		if len(variations) == 0 {
+			fmt.Println(section)
+			continue
+		}
+		fmt.Printf("%s: %s\n", section, strings.Join(variations, ", "))
+	}
+	return nil
+}
+
+// Execute the lib describe command
+func (ld *LibDescribeCommand) Execute(args []string) error {
+	gtor := (&Command{Library: ld.lib.Library}).New()
+	if ld.Args.Variation != "" {
+		if err := gtor.CheckVariation(ld.Args.Section, ld.Args.Variation); err != nil {
+			return err
+		}
+	}
+	if err := gtor.LoadSectionGenerators(ld.Args.Section, ld.Args.Variation); err != nil {
+		return err
+	}
+	title := ld.Args.Section
+	if ld.Args.Variation != "" {
+		title = fmt.Sprintf("%s:%s", ld.Args.Section, ld.Args.Variation)
+	}
+	fmt.Println("section", title)
+	for _, tool := range gtor.Requires {
+		fmt.Printf("    requires %s version:%q constraint:%q\n", tool.Name, tool.Keys["version"], tool.Keys["constraint"])
+	}
+	gtor.Catalog.Describe()
+	return nil
+}
+
+// Describe prints the templates and actions for each element in the catalog
+func (cat Catalog) Describe() {
+	elems := []string{}
+	for elem := range cat {
+		elems = append(elems, elem)
+	}
+	sort.Strings(elems)
+	for _, elem := range elems {
+		agenda := cat[elem]
+		fmt.Println("    element", elem)
+		for _, tmpl := range agenda.Templates.List {
+			if delims, ok := tmpl.Keys["delims"]; ok {
+				fmt.Printf("        template:%s file:%q delims:%q\n", tmpl.Name, tmpl.Keys["file"], delims)
+				continue
+			}
+			fmt.Printf("        template:%s file:%q\n", tmpl.Name, tmpl.Keys["file"])
+		}
+		for _, action := range agenda.Actions.List {
+			if generate, ok := action.Keys["generate"]; ok {
+				fmt.Printf("        action:%s generate:%q into:%q\n", action.Name, generate, action.Keys["into"])
+				continue
+			}
+			if builtin, ok := action.Keys["builtin"]; ok {
+				fmt.Printf("        action:%s builtin:%s args:%q\n", action.Name, builtin, action.Keys["args"])
+				continue
+			}
+			if action.Content != "" {
+				fmt.Printf("        action:%s script:%q\n", action.Name, action.Content)
+				continue
+			}
+			fmt.Printf("        action:%s exec:%q\n", action.Name, action.Keys["exec"])
+		}
+	}
+}
+
+// CheckVariation reports a variation the section type does not have, naming those it has
+func (gtor *Generator) CheckVariation(sectionType, variation string) error {
+	names, err := gtor.VariationNames(sectionType)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if names[variation] {
+		return nil
+	}
+	variations := []string{}
+	for name := range names {
+		variations = append(variations, name)
+	}
+	if len(variations) == 0 {
+		return fmt.Errorf("section %s has no variations", sectionType)
+	}
+	sort.Strings(variations)
+	return fmt.Errorf("section %s has no variation %s, it has: %s", sectionType, variation, strings.Join(variations, ", "))
+}
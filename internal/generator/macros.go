@@ -88,7 +88,10 @@ func (cmd *Command) ExpandProjectMacros(project *brief.Node) error {
 	for {
 		for _, section := range current {
 			gtor := cmd.New()
-
+			// macro templates are parsed with the delimiters their generators give them
+			if err := gtor.LoadSectionDelims(section); err != nil {
+				return err
+			}
 			if err := gtor.LoadSectionTemplates(section); err != nil {
 				return err
 			}
@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// ManifestDir holds brevity state inside a generated project
+const ManifestDir = ".brevity"
+
+// ManifestName of the manifest file inside the ManifestDir
+const ManifestName = "manifest.json"
+
+// FailedName of the manifest written when generation does not complete
+const FailedName = "failed.json"
+
+// ManifestFile records a file written by a template
+type ManifestFile struct {
+	File      string `json:"file"`
+	Template  string `json:"template"`
+	Node      string `json:"node"`
+	Hash      string `json:"hash"`
+	Generator string `json:"generator,omitempty"`
+	Source    string `json:"source,omitempty"`
+}
+
+// ManifestAction records an action that ran and how it exited
+type ManifestAction struct {
+	Action    string   `json:"action"`
+	Node      string   `json:"node"`
+	Generator string   `json:"generator,omitempty"`
+	Command   []string `json:"command"`
+	Exit      int      `json:"exit"`
+	Error     string   `json:"error,omitempty"`
+	Skipped   string   `json:"skipped,omitempty"`
+}
+
+// ManifestOrphan keeps a protected region the templates no longer generate
+type ManifestOrphan struct {
+	File    string `json:"file"`
+	Region  string `json:"region"`
+	Content string `json:"content"`
+}
+
+// Manifest of what brevity generated in a project
+type Manifest struct {
+	Project  string            `json:"project"`
+	Spec     string            `json:"spec"`
+	Library  string            `json:"library"`
+	Files    []*ManifestFile   `json:"files"`
+	Actions  []*ManifestAction `json:"actions"`
+	Orphans  []*ManifestOrphan `json:"orphans,omitempty"`
+	Status   string            `json:"status,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Log      string            `json:"log,omitempty"`
+	dir      string
+	previous *Manifest
+	mu       sync.Mutex
+}
+
+// NewManifest for a project generated into dir
+func NewManifest(project, dir string) *Manifest {
+	return &Manifest{
+		Project: project,
+		Files:   []*ManifestFile{},
+		Actions: []*ManifestAction{},
+		dir:     dir,
+	}
+}
+
+// ReadManifest from a project folder, nil when there is none
+func ReadManifest(dir string) (*Manifest, error) {
+	return readManifest(dir, ManifestName)
+}
+
+// ReadFailedManifest left by a generation that did not complete, nil when there is none
+func ReadFailedManifest(dir string) (*Manifest, error) {
+	return readManifest(dir, FailedName)
+}
+
+func readManifest(dir, name string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestDir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{dir: dir}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Write the manifest into its project folder, clearing any failed manifest
+func (m *Manifest) Write() error {
+	if err := m.write(ManifestName); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(m.dir, ManifestDir, FailedName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// WriteFailed records a generation that did not complete so that a later run can detect it
+func (m *Manifest) WriteFailed(status string, failure error) error {
+	m.Status = status
+	m.Error = failure.Error()
+	return m.write(FailedName)
+}
+
+func (m *Manifest) write(name string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := MakeFolder(m.dir, ManifestDir); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(m.dir, ManifestDir, name), append(data, '\n'), 0644)
+}
+
+// RelPath of a file within the project folder
+func (m *Manifest) RelPath(filename string) string {
+	rel, err := filepath.Rel(m.dir, filename)
+	if err != nil {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+// AddFile records a generated file, replacing an earlier record for the same file
+func (m *Manifest) AddFile(file *ManifestFile) {
+	for i, f := range m.Files {
+		if f.File == file.File {
+			m.Files[i] = file
+			return
+		}
+	}
+	m.Files = append(m.Files, file)
+}
+
+// File record for a path relative to the project folder
+func (m *Manifest) File(name string) *ManifestFile {
+	for _, f := range m.Files {
+		if f.File == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// SetPrevious manifest from the last run, keeping the orphaned regions of files still on disk
+func (m *Manifest) SetPrevious(previous *Manifest) {
+	m.previous = previous
+	if previous == nil {
+		return
+	}
+	for _, orphan := range previous.Orphans {
+		if _, err := os.Stat(filepath.Join(m.dir, filepath.FromSlash(orphan.File))); err == nil {
+			m.Orphans = append(m.Orphans, orphan)
+		}
+	}
+}
+
+// KeepPrevious carries the last run's record of a file into this manifest
+func (m *Manifest) KeepPrevious(name string) *ManifestFile {
+	if m.previous == nil {
+		return nil
+	}
+	file := m.previous.File(name)
+	if file != nil {
+		m.AddFile(file)
+	}
+	return file
+}
+
+// AddAction records an action and its exit status, replacing the record of an earlier
+// attempt of the same action on the node, so that a retried action shows its final outcome
+func (m *Manifest) AddAction(name, node, genfile string, args []string, err error) {
+	record := &ManifestAction{
+		Action:    name,
+		Node:      node,
+		Generator: genfile,
+		Command:   args,
+	}
+	if err != nil {
+		record.Error = err.Error()
+		record.Exit = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			record.Exit = exitErr.ExitCode()
+		}
+	}
+	m.addAction(record)
+}
+
+// SkipAction records an action that did not need to run and why, replacing an earlier record of it
+func (m *Manifest) SkipAction(name, node, genfile string, args []string, reason string) {
+	m.addAction(&ManifestAction{
+		Action:    name,
+		Node:      node,
+		Generator: genfile,
+		Command:   args,
+		Skipped:   reason,
+	})
+}
+
+// addAction replaces the record of the same action on the node, or adds it
+func (m *Manifest) addAction(record *ManifestAction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, a := range m.Actions {
+		if a.Action == record.Action && a.Node == record.Node && a.Generator == record.Generator {
+			m.Actions[i] = record
+			return
+		}
+	}
+	m.Actions = append(m.Actions, record)
+}
+
+// AddOrphan records a protected region dropped from a generated file,
+// replacing an earlier record of the same region
+func (m *Manifest) AddOrphan(file string, region *Region) {
+	orphan := &ManifestOrphan{
+		File:    file,
+		Region:  region.ID,
+		Content: region.Content,
+	}
+	for i, o := range m.Orphans {
+		if o.File == file && o.Region == region.ID {
+			m.Orphans[i] = orphan
+			return
+		}
+	}
+	m.Orphans = append(m.Orphans, orphan)
+}
+
+// ContentHash of generated file contents
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	manifest := NewManifest("p", dir)
+	manifest.Spec = "/specs/app.brief"
+	manifest.Library = "/lib"
+	manifest.AddFile(&ManifestFile{File: "main.go", Template: "main", Node: "project:p", Hash: ContentHash([]byte("old"))})
+	manifest.AddFile(&ManifestFile{File: "main.go", Template: "main", Node: "project:p", Hash: ContentHash([]byte("new"))})
+	manifest.AddAction("build", "project:p", "go/generator.brief", []string{"go", "build"}, nil)
+	if err := manifest.WriteFailed("failed", errors.New("build broke")); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := ReadFailedManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed == nil || failed.Status != "failed" || failed.Error != "build broke" {
+		t.Fatalf("got failed manifest %+v", failed)
+	}
+	manifest.Status, manifest.Error = "", ""
+	if err := manifest.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ManifestDir, FailedName)); !os.IsNotExist(err) {
+		t.Errorf("failed manifest left after a complete run: %v", err)
+	}
+	read, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Files, manifest.Files) || !reflect.DeepEqual(read.Actions, manifest.Actions) {
+		t.Errorf("got files %+v actions %+v", read.Files, read.Actions)
+	}
+	if len(read.Files) != 1 || read.Files[0].Hash != ContentHash([]byte("new")) {
+		t.Errorf("file record not replaced: %+v", read.Files)
+	}
+	if read.Spec != manifest.Spec || read.Library != manifest.Library {
+		t.Errorf("got spec %s library %s", read.Spec, read.Library)
+	}
+}
+
+func TestReadManifestMissing(t *testing.T) {
+	manifest, err := ReadManifest(t.TempDir())
+	if manifest != nil || err != nil {
+		t.Errorf("got manifest %+v, error %v", manifest, err)
+	}
+}
+
+func TestManifestPrevious(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeTestFile(dir, "kept.go", "x"); err != nil {
+		t.Fatal(err)
+	}
+	previous := NewManifest("p", dir)
+	previous.AddFile(&ManifestFile{File: "kept.go", Template: "kept"})
+	previous.Orphans = []*ManifestOrphan{{File: "kept.go", Region: "a"}, {File: "gone.go", Region: "b"}}
+	manifest := NewManifest("p", dir)
+	manifest.SetPrevious(previous)
+	if len(manifest.Orphans) != 1 || manifest.Orphans[0].File != "kept.go" {
+		t.Errorf("got orphans %+v, want those of files on disk", manifest.Orphans)
+	}
+	if file := manifest.KeepPrevious("kept.go"); file == nil || manifest.File("kept.go") != file {
+		t.Errorf("previous record not kept: %+v", file)
+	}
+	if file := manifest.KeepPrevious("other.go"); file != nil {
+		t.Errorf("got record %+v for a file never generated", file)
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	dir := t.TempDir()
+	manifest := NewManifest("p", dir)
+	tests := []struct {
+		name, want string
+	}{
+		{filepath.Join(dir, "cmd", "main.go"), "cmd/main.go"},
+		{filepath.Join(dir, "go.mod"), "go.mod"},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := manifest.RelPath(test.name); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
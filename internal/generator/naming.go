@@ -0,0 +1,333 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// NamingFuncs converts names in the spec to the identifiers of the generated code
+var NamingFuncs = template.FuncMap{
+	"goExported":   GoExported,
+	"goUnexported": GoUnexported,
+	"pascal":       Pascal,
+	"camel":        Camel,
+	"snake":        Snake,
+	"kebab":        Kebab,
+	"screaming":    Screaming,
+	"plural":       pluralFunc,
+	"singular":     Singular,
+	"goSafe":       escapeReserved(goReserved),
+	"tsSafe":       escapeReserved(tsReserved),
+	"pySafe":       escapeReserved(pyReserved),
+	"javaSafe":     escapeReserved(javaReserved),
+}
+
+// initialisms that Go names keep in a single case, as golint does
+var initialisms = wordSet(`ACL API ASCII CPU CSS CSV DNS EOF GUID HTML HTTP HTTPS ID IP JSON JWT LHS QPS RAM RHS
+	RPC SLA SMTP SQL SSH TCP TLS TTL UDP UI UID UUID URI URL UTF8 VM XML XMPP XSRF XSS YAML`)
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// wordSpans finds the words of a name as rune offsets.  Words are separated by anything
+// but letters and digits, and by changes of case, so HTTPServer is HTTP Server and userIDs is user IDs.
+func wordSpans(name []rune) [][2]int {
+	spans := [][2]int{}
+	start := -1
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := name[i-1]
+			// an acronym ends before the upper case letter starting the next word, unless it is a plural s
+			lowerNext := i+1 < len(name) && unicode.IsLower(name[i+1])
+			pluralNext := lowerNext && name[i+1] == 's' && (i+2 == len(name) || !unicode.IsLower(name[i+2]))
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && lowerNext && !pluralNext) {
+				spans = append(spans, [2]int{start, i})
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(name)})
+	}
+	return spans
+}
+
+// Words of a name, split on separators and changes of case
+func Words(name string) []string {
+	runes := []rune(name)
+	words := []string{}
+	for _, span := range wordSpans(runes) {
+		words = append(words, string(runes[span[0]:span[1]]))
+	}
+	return words
+}
+
+func title(word string) string {
+	runes := []rune(strings.ToLower(word))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// initialism of a word in upper case, keeping the s of a plural like IDs
+func initialism(word string) (string, bool) {
+	upper := strings.ToUpper(word)
+	if initialisms[upper] {
+		return upper, true
+	}
+	if strings.HasSuffix(word, "s") && initialisms[upper[:len(upper)-1]] {
+		return upper[:len(upper)-1] + "s", true
+	}
+	return "", false
+}
+
+// GoExported name with Go initialisms in upper case, user_id is UserID
+func GoExported(name string) string {
+	var b strings.Builder
+	for _, word := range Words(name) {
+		if upper, ok := initialism(word); ok {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(title(word))
+	}
+	return b.String()
+}
+
+// GoUnexported name with Go initialisms in a single case, HTTPServer is httpServer
+func GoUnexported(name string) string {
+	words := Words(name)
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToLower(words[0]) + GoExported(strings.Join(words[1:], " "))
+}
+
+// Pascal case name with acronyms as words, HTTPServer is HttpServer
+func Pascal(name string) string {
+	var b strings.Builder
+	for _, word := range Words(name) {
+		b.WriteString(title(word))
+	}
+	return b.String()
+}
+
+// Camel case name with acronyms as words, HTTPServer is httpServer
+func Camel(name string) string {
+	words := Words(name)
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToLower(words[0]) + Pascal(strings.Join(words[1:], " "))
+}
+
+// Snake case name, HTTPServer is http_server
+func Snake(name string) string {
+	return strings.ToLower(strings.Join(Words(name), "_"))
+}
+
+// Kebab case name, HTTPServer is http-server
+func Kebab(name string) string {
+	return strings.ToLower(strings.Join(Words(name), "-"))
+}
+
+// Screaming snake case name, HTTPServer is HTTP_SERVER
+func Screaming(name string) string {
+	return strings.ToUpper(strings.Join(Words(name), "_"))
+}
+
+type inflection struct {
+	match   *regexp.Regexp
+	replace string
+}
+
+func inflections(rules ...string) []inflection {
+	list := []inflection{}
+	for i := 0; i < len(rules); i += 2 {
+		list = append(list, inflection{regexp.MustCompile(rules[i]), rules[i+1]})
+	}
+	return list
+}
+
+// rules to change the number of a lower case word, the first that matches applies
+var (
+	pluralRules = inflections(
+		`(quiz)$`, `${1}zes`,
+		`(matr)ix$`, `${1}ices`,
+		`(vert|ind)ex$`, `${1}ices`,
+		`sis$`, `ses`,
+		`(x|ch|sh|zz|s)$`, `${1}es`,
+		`([^aeiouy]|qu)y$`, `${1}ies`,
+		`(kni|wi|li)fe$`, `${1}ves`,
+		`(wol|hal|el|cal|dwar|scar|lea|loa|thie)f$`, `${1}ves`,
+		`$`, `s`,
+	)
+	// the stems of whole words are anchored, so databases is database and not databasis
+	singularRules = inflections(
+		`(quiz)zes$`, `${1}`,
+		`(matr)ices$`, `${1}ix`,
+		`(vert|ind)ices$`, `${1}ex`,
+		`^(analy|ba|diagno|parenthe|progno|synop|the|cri|hypothe)ses$`, `${1}sis`,
+		`^(alias|status|bus|campus|virus|census|gas|lens|canvas|bonus|corpus|focus)es$`, `${1}`,
+		`(^ache|headache|cache|niche|avalanche|cliche|moustache|psyche)s$`, `${1}`,
+		`(x|ch|sh|zz|ss)es$`, `${1}`,
+		`^(pie|tie|lie|die)s$`, `${1}`,
+		`(movie|cookie|rookie|zombie|selfie|hippie|calorie|genie|goalie|newbie|prairie|smoothie|brownie)s$`, `${1}`,
+		`([^aeiouy]|qu)ies$`, `${1}y`,
+		`(kni|wi|^li)ves$`, `${1}fe`,
+		`(wol|hal|el|cal|dwar|scar|lea|loa|thie)ves$`, `${1}f`,
+		`(ss|us|is)$`, `${1}`,
+		`s$`, ``,
+	)
+)
+
+var (
+	uncountable = wordSet(`data deer equipment feedback firmware fish hardware information metadata money
+		moose news rice series sheep software species`)
+	irregularPlurals = map[string]string{
+		"child":      "children",
+		"criterion":  "criteria",
+		"echo":       "echoes",
+		"foot":       "feet",
+		"goose":      "geese",
+		"hero":       "heroes",
+		"man":        "men",
+		"mouse":      "mice",
+		"ox":         "oxen",
+		"person":     "people",
+		"phenomenon": "phenomena",
+		"potato":     "potatoes",
+		"tomato":     "tomatoes",
+		"tooth":      "teeth",
+		"veto":       "vetoes",
+		"woman":      "women",
+	}
+	irregularSingulars = map[string]string{}
+)
+
+func init() {
+	for singular, plural := range irregularPlurals {
+		irregularSingulars[plural] = singular
+	}
+}
+
+// inflect the last word of a name to the plural or singular, keeping its case
+func inflect(name string, plural bool) string {
+	runes := []rune(name)
+	spans := wordSpans(runes)
+	if len(spans) == 0 {
+		return name
+	}
+	last := spans[len(spans)-1]
+	word := string(runes[last[0]:last[1]])
+	irregular, rules := irregularSingulars, singularRules
+	if plural {
+		irregular, rules = irregularPlurals, pluralRules
+	}
+	lower := strings.ToLower(word)
+	result := lower
+	switch upper, ok := initialism(word); {
+	case ok:
+		// initialisms only gain or lose an s, ID and IDs
+		result = word
+		if strings.HasSuffix(upper, "s") {
+			result = word[:len(word)-1]
+		}
+		if plural {
+			result += "s"
+		}
+		return string(runes[:last[0]]) + result + string(runes[last[1]:])
+	case uncountable[lower]:
+	case irregular[lower] != "":
+		result = irregular[lower]
+	default:
+		for _, rule := range rules {
+			if rule.match.MatchString(lower) {
+				result = rule.match.ReplaceAllString(lower, rule.replace)
+				break
+			}
+		}
+	}
+	switch {
+	case word == strings.ToUpper(word) && len(word) > 1:
+		result = strings.ToUpper(result)
+	case unicode.IsUpper(runes[last[0]]):
+		result = title(result)
+	}
+	return string(runes[:last[0]]) + result + string(runes[last[1]:])
+}
+
+// Plural of a name, changing its last English word, user_account is user_accounts
+func Plural(name string) string {
+	return inflect(name, true)
+}
+
+// Singular of a name, changing its last English word, Categories is Category
+func Singular(name string) string {
+	return inflect(name, false)
+}
+
+// pluralFunc is Plural given a name, and sprig's plural given one, many and a count
+func pluralFunc(args ...interface{}) (string, error) {
+	switch len(args) {
+	case 1:
+		name, ok := args[0].(string)
+		if !ok {
+			return "", fmt.Errorf("plural: %v is not a string", args[0])
+		}
+		return Plural(name), nil
+	case 3:
+		count := reflect.ValueOf(args[2])
+		switch count.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if count.Int() == 1 {
+				return fmt.Sprint(args[0]), nil
+			}
+			return fmt.Sprint(args[1]), nil
+		}
+		return "", fmt.Errorf("plural: count %v is not an integer", args[2])
+	}
+	return "", fmt.Errorf("plural takes a name, or one, many and a count")
+}
+
+// reserved words of each language, escaped by adding an underscore
+var (
+	goReserved = wordSet(`break case chan const continue default defer else fallthrough for func go goto if
+		import interface map package range return select struct switch type var`)
+	tsReserved = wordSet(`break case catch class const continue debugger default delete do else enum export extends
+		false finally for function if implements import in instanceof interface let new null package private
+		protected public return static super switch this throw true try typeof var void while with yield`)
+	pyReserved = wordSet(`False None True and as assert async await break class continue def del elif else
+		except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield`)
+	javaReserved = wordSet(`_ abstract assert boolean break byte case catch char class const continue default do
+		double else enum extends false final finally float for goto if implements import instanceof int interface
+		long native new null package private protected public return short static strictfp super switch
+		synchronized this throw throws transient true try var void volatile while`)
+)
+
+func escapeReserved(reserved map[string]bool) func(string) string {
+	return func(name string) string {
+		if reserved[name] {
+			return name + "_"
+		}
+		return name
+	}
+}
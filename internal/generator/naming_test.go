@@ -0,0 +1,179 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"user", "user"},
+		{"user_account", "user account"},
+		{"kebab-case name", "kebab case name"},
+		{"UserAccount", "User Account"},
+		{"HTTPServer", "HTTP Server"},
+		{"HTTPServers", "HTTP Servers"},
+		{"userIDs", "user IDs"},
+		{"userIDsList", "user IDs List"},
+		{"parseURLString", "parse URL String"},
+		{"myHTTPSProxy", "my HTTPS Proxy"},
+		{"URLs", "URLs"},
+		{"v2Api", "v2 Api"},
+		{"__", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := strings.Join(Words(test.name), " "); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCaseNames(t *testing.T) {
+	tests := []struct {
+		name                                                             string
+		goExported, goUnexported, pascal, camel, snake, kebab, screaming string
+	}{
+		{"user_id", "UserID", "userID", "UserId", "userId", "user_id", "user-id", "USER_ID"},
+		{"HTTPServer", "HTTPServer", "httpServer", "HttpServer", "httpServer", "http_server", "http-server", "HTTP_SERVER"},
+		{"userIDs", "UserIDs", "userIDs", "UserIds", "userIds", "user_ids", "user-ids", "USER_IDS"},
+		{"api url", "APIURL", "apiURL", "ApiUrl", "apiUrl", "api_url", "api-url", "API_URL"},
+		{"", "", "", "", "", "", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []string{GoExported(test.name), GoUnexported(test.name), Pascal(test.name), Camel(test.name),
+				Snake(test.name), Kebab(test.name), Screaming(test.name)}
+			want := []string{test.goExported, test.goUnexported, test.pascal, test.camel, test.snake, test.kebab, test.screaming}
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPluralRoundTrip(t *testing.T) {
+	tests := []struct {
+		singular, plural string
+	}{
+		{"user", "users"},
+		{"day", "days"},
+		{"category", "categories"},
+		{"copy", "copies"},
+		{"box", "boxes"},
+		{"beach", "beaches"},
+		{"approach", "approaches"},
+		{"address", "addresses"},
+		{"class", "classes"},
+		{"bus", "buses"},
+		{"status", "statuses"},
+		{"alias", "aliases"},
+		{"abuse", "abuses"},
+		{"quiz", "quizzes"},
+		{"matrix", "matrices"},
+		{"index", "indices"},
+		{"vertex", "vertices"},
+		{"analysis", "analyses"},
+		{"crisis", "crises"},
+		{"database", "databases"},
+		{"cache", "caches"},
+		{"niche", "niches"},
+		{"ache", "aches"},
+		{"headache", "headaches"},
+		{"movie", "movies"},
+		{"cookie", "cookies"},
+		{"pie", "pies"},
+		{"tie", "ties"},
+		{"party", "parties"},
+		{"knife", "knives"},
+		{"wife", "wives"},
+		{"life", "lives"},
+		{"olive", "olives"},
+		{"wolf", "wolves"},
+		{"leaf", "leaves"},
+		{"shelf", "shelves"},
+		{"bookshelf", "bookshelves"},
+		{"child", "children"},
+		{"person", "people"},
+		{"hero", "heroes"},
+		{"data", "data"},
+		{"series", "series"},
+		{"ID", "IDs"},
+		{"user_account", "user_accounts"},
+		{"UserAccount", "UserAccounts"},
+		{"HTTPServer", "HTTPServers"},
+		{"userID", "userIDs"},
+		{"ApiCache", "ApiCaches"},
+		{"USER", "USERS"},
+		{"Person", "People"},
+	}
+	for _, test := range tests {
+		t.Run(test.singular, func(t *testing.T) {
+			if got := Plural(test.singular); got != test.plural {
+				t.Errorf("plural got %q, want %q", got, test.plural)
+			}
+			if got := Singular(test.plural); got != test.singular {
+				t.Errorf("singular of %s got %q, want %q", test.plural, got, test.singular)
+			}
+		})
+	}
+}
+
+func TestPluralFunc(t *testing.T) {
+	tests := []struct {
+		name string
+		args []interface{}
+		want string
+		err  bool
+	}{
+		{"name", []interface{}{"user"}, "users", false},
+		{"one", []interface{}{"item", "items", 1}, "item", false},
+		{"many", []interface{}{"item", "items", 2}, "items", false},
+		{"int64 count", []interface{}{"item", "items", int64(1)}, "item", false},
+		{"count not an integer", []interface{}{"item", "items", "1"}, "", true},
+		{"name not a string", []interface{}{1}, "", true},
+		{"two args", []interface{}{"item", "items"}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := pluralFunc(test.args...)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEscapeReserved(t *testing.T) {
+	tests := []struct {
+		reserved map[string]bool
+		name     string
+		want     string
+	}{
+		{goReserved, "type", "type_"},
+		{goReserved, "user", "user"},
+		{tsReserved, "class", "class_"},
+		{tsReserved, "interface", "interface_"},
+		{tsReserved, "string", "string"},
+		{tsReserved, "number", "number"},
+		{tsReserved, "type", "type"},
+		{tsReserved, "from", "from"},
+		{pyReserved, "None", "None_"},
+		{pyReserved, "none", "none"},
+		{javaReserved, "int", "int_"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := escapeReserved(test.reserved)(test.name); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robbyriverside/brief"
+)
+
+// MaxGenerateDepth limits chains of generate actions whose emitted specs differ at every level
+const MaxGenerateDepth = 8
+
+// ActionGenerate expands the spec file and destination folder of a generate action,
+// relative to the action folder.  The destination must stay within the project.
+func (gtor *Generator) ActionGenerate(action, spec *brief.Node, dir string) (specfile, destination string, err error) {
+	specfile, err = gtor.ExecValueTemplate(action.Keys["generate"], spec)
+	if err != nil {
+		return "", "", fmt.Errorf("action %s generate: %s", action.Name, err)
+	}
+	into, ok := action.Keys["into"]
+	if !ok {
+		return resolve(dir, specfile), dir, nil
+	}
+	into, err = gtor.ExecValueTemplate(into, spec)
+	if err != nil {
+		return "", "", fmt.Errorf("action %s into: %s", action.Name, err)
+	}
+	destination, err = gtor.ActionDir(&brief.Node{Name: action.Name, Keys: map[string]string{"dir": into}}, spec, dir)
+	if err != nil {
+		return "", "", err
+	}
+	return resolve(dir, specfile), destination, nil
+}
+
+// StartChain of generated specs with the spec of this run.  It is started before any action
+// runs, as the generate actions of parallel steps share it.
+func (cmd *Command) StartChain() error {
+	data, err := ioutil.ReadFile(cmd.Args.SpecFile)
+	if err != nil {
+		return err
+	}
+	cmd.chain = NewFileSet().Add(ContentHash(data))
+	cmd.chainFiles = []string{filepath.Join(cmd.specDir, filepath.Base(cmd.Args.SpecFile))}
+	return nil
+}
+
+// Nested generates a spec emitted by an action into destination, with the same library and options.
+// The specs being generated are tracked by content, so a spec that emits itself is stopped.
+func (cmd *Command) Nested(ctx context.Context, specfile, destination string) error {
+	if cmd.chain == nil {
+		return fmt.Errorf("generate %s: the chain of generated specs was not started", specfile)
+	}
+	files := append(append([]string{}, cmd.chainFiles...), specfile)
+	if len(files) > MaxGenerateDepth {
+		return fmt.Errorf("generate chain deeper than %d: %s", MaxGenerateDepth, strings.Join(files, " -> "))
+	}
+	data, err := ioutil.ReadFile(specfile)
+	if err != nil {
+		return err
+	}
+	chain := cmd.chain.Copy().Add(ContentHash(data))
+	if chain.Err != nil {
+		return fmt.Errorf("recursive generate: %s", strings.Join(files, " -> "))
+	}
+	if err := os.MkdirAll(destination, os.ModePerm); err != nil {
+		return err
+	}
+
+	nested := &Command{
+		Library:       cmd.Library,
+		Render:        cmd.Render,
+		ActionTimeout: cmd.ActionTimeout,
+		Shell:         cmd.Shell,
+		Jobs:          cmd.Jobs,
+		AllowExec:     cmd.AllowExec,
+		Confirm:       cmd.Confirm,
+		NoExec:        cmd.NoExec,
+		runLog:        cmd.runLog,
+		policy:        cmd.policy,
+		summary:       cmd.summary,
+		KeepGoing:     cmd.KeepGoing,
+		chain:         chain,
+		chainFiles:    files,
+	}
+	nested.Args.SpecFile = specfile
+	nested.Args.Destination = destination
+	spec, err := nested.ReadSpec()
+	if err != nil {
+		return err
+	}
+	nested.runLog.Printf("=== generate %s into %s\n", specfile, destination)
+	return nested.Generate(ctx, spec)
+}
@@ -0,0 +1,84 @@
+package generator
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNestedChain(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"top.brief": "top", "same.brief": "top", "other.brief": "other"} {
+		if err := writeTestFile(dir, name, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deep := []string{}
+	for len(deep) < MaxGenerateDepth {
+		deep = append(deep, "spec.brief")
+	}
+	tests := []struct {
+		name   string
+		start  bool
+		files  []string
+		nested string
+		err    string
+	}{
+		{"not started", false, nil, "other.brief", "the chain of generated specs was not started"},
+		{"same spec", true, nil, "same.brief", "recursive generate: "},
+		{"too deep", true, deep, "other.brief", "generate chain deeper than 8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &Command{}
+			cmd.Args.SpecFile = filepath.Join(dir, "top.brief")
+			if test.start {
+				if err := cmd.StartChain(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.files != nil {
+				cmd.chainFiles = test.files
+			}
+			err := cmd.Nested(context.Background(), filepath.Join(dir, test.nested), dir)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestGenerateSelf(t *testing.T) {
+	spec := "brevity\n    project:p\n        app:x\n"
+	lib := t.TempDir()
+	genfile := "generator\n    templates\n        template:self file:\"self.brief\" element:project\n" +
+		"    actions\n        action:again generate:\"self.brief\" element:project\n"
+	if err := writeTestFile(lib, "app/generator.brief", genfile); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(lib, "app/templates/self.tmpl", `{{ define "self" }}`+spec+`{{ end }}`); err != nil {
+		t.Fatal(err)
+	}
+	for _, jobs := range []int{1, 2} {
+		dir := t.TempDir()
+		if err := writeTestFile(dir, "spec.brief", spec); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeTestFile(dir, "out/.keep", ""); err != nil {
+			t.Fatal(err)
+		}
+		cmd := &Command{Library: lib, Jobs: jobs}
+		cmd.Args.SpecFile = filepath.Join(dir, "spec.brief")
+		cmd.Args.Destination = filepath.Join(dir, "out")
+		node, err := cmd.ReadSpec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the chain starts with the spec of the run, so its first generate action is stopped
+		err = cmd.Generate(context.Background(), node)
+		if err == nil || !strings.Contains(err.Error(), "recursive generate") {
+			t.Errorf("jobs %d: got error %v, want a recursive generate", jobs, err)
+		}
+	}
+}
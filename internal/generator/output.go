@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LogDir holds the action logs of each run inside the destination ManifestDir
+const LogDir = "logs"
+
+// stdoutMu keeps lines from different actions whole on stdout
+var stdoutMu sync.Mutex
+
+// RunLog collects the full output of every action in one generate run
+type RunLog struct {
+	Path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenRunLog creates a new log file for this run under the destination
+func OpenRunLog(destination string) (*RunLog, error) {
+	dir := filepath.Join(destination, ManifestDir, LogDir)
+	if err := MakeFolder(dir); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102-150405.000")+".log")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &RunLog{Path: path, file: file}, nil
+}
+
+// Printf a line into the log
+func (rl *RunLog) Printf(format string, args ...interface{}) {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	fmt.Fprintf(rl.file, format, args...)
+}
+
+func (rl *RunLog) write(data []byte) {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.file.Write(data)
+}
+
+// Close the log file
+func (rl *RunLog) Close() error {
+	if rl == nil {
+		return nil
+	}
+	return rl.file.Close()
+}
+
+// ActionOutput splits action output into lines prefixed with the action and spec node.
+// Lines go to the run log, and to stdout when echo is set.
+// Grouped output is held back until Flush so it is not interleaved with other actions.
+type ActionOutput struct {
+	Group  bool
+	mu     sync.Mutex
+	prefix string
+	log    *RunLog
+	echo   bool
+	all    bytes.Buffer
+	line   []byte
+	block  bytes.Buffer
+}
+
+// NewActionOutput for an action running on a spec node
+func NewActionOutput(prefix string, log *RunLog, echo bool) *ActionOutput {
+	return &ActionOutput{
+		prefix: fmt.Sprintf("[%s] ", prefix),
+		log:    log,
+		echo:   echo,
+	}
+}
+
+// Write output from the action
+func (ao *ActionOutput) Write(data []byte) (int, error) {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+	ao.all.Write(data)
+	ao.line = append(ao.line, data...)
+	for {
+		i := bytes.IndexByte(ao.line, '\n')
+		if i < 0 {
+			break
+		}
+		ao.emit(ao.line[:i+1])
+		ao.line = ao.line[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush a final line without a newline, and any grouped output
+func (ao *ActionOutput) Flush() {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+	if len(ao.line) > 0 {
+		ao.emit(append(ao.line, '\n'))
+		ao.line = nil
+	}
+	if ao.block.Len() > 0 {
+		ao.write(ao.block.Bytes())
+		ao.block.Reset()
+	}
+}
+
+// Bytes of all the output without prefixes
+func (ao *ActionOutput) Bytes() []byte {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+	return ao.all.Bytes()
+}
+
+func (ao *ActionOutput) emit(line []byte) {
+	prefixed := append([]byte(ao.prefix), line...)
+	if ao.Group {
+		ao.block.Write(prefixed)
+		return
+	}
+	ao.write(prefixed)
+}
+
+func (ao *ActionOutput) write(data []byte) {
+	ao.log.write(data)
+	if ao.echo {
+		stdoutMu.Lock()
+		os.Stdout.Write(data)
+		stdoutMu.Unlock()
+	}
+}
+
+var _ io.Writer = (*ActionOutput)(nil)
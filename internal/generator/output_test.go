@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestActionOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		group  bool
+		writes []string
+		early  string
+		want   string
+	}{
+		{"lines", false, []string{"one\ntwo\n"}, "[a] one\n[a] two\n", "[a] one\n[a] two\n"},
+		{"split line", false, []string{"o", "ne\ntw", "o\n"}, "[a] one\n[a] two\n", "[a] one\n[a] two\n"},
+		{"no final newline", false, []string{"one\ntwo"}, "[a] one\n", "[a] one\n[a] two\n"},
+		{"empty line", false, []string{"\n"}, "[a] \n", "[a] \n"},
+		{"grouped", true, []string{"one\n", "two"}, "", "[a] one\n[a] two\n"},
+		{"nothing", true, nil, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log, err := OpenRunLog(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer log.Close()
+			out := NewActionOutput("a", log, false)
+			out.Group = test.group
+			all := ""
+			for _, data := range test.writes {
+				if _, err := out.Write([]byte(data)); err != nil {
+					t.Fatal(err)
+				}
+				all += data
+			}
+			if got := readLog(t, log); got != test.early {
+				t.Errorf("before flush got %q, want %q", got, test.early)
+			}
+			out.Flush()
+			if got := readLog(t, log); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if got := string(out.Bytes()); got != all {
+				t.Errorf("got output %q, want %q", got, all)
+			}
+		})
+	}
+}
+
+func readLog(t *testing.T, log *RunLog) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(log.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNilRunLog(t *testing.T) {
+	var log *RunLog
+	log.Printf("ignored %d", 1)
+	if err := log.Close(); err != nil {
+		t.Error(err)
+	}
+	out := NewActionOutput("a", log, false)
+	if _, err := out.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	out.Flush()
+	if got := string(out.Bytes()); got != "line\n" {
+		t.Errorf("got %q", got)
+	}
+}
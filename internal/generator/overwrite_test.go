@@ -0,0 +1,81 @@
+package generator
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestOverwrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		existing string
+		previous string
+		want     bool
+	}{
+		{"default", "", "edited", "generated", true},
+		{"always", OverwriteAlways, "edited", "generated", true},
+		{"never", OverwriteNever, "generated", "generated", false},
+		{"never a new file", OverwriteNever, "", "", true},
+		{"unchanged", OverwriteIfUnchanged, "generated", "generated", true},
+		{"changed", OverwriteIfUnchanged, "edited", "generated", false},
+		{"never generated", OverwriteIfUnchanged, "mine", "", false},
+		{"if unchanged a new file", OverwriteIfUnchanged, "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "execute.go")
+			if test.existing != "" {
+				if err := writeTestFile(dir, "execute.go", test.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			previous := NewManifest("p", dir)
+			if test.previous != "" {
+				previous.AddFile(&ManifestFile{File: "execute.go", Template: "execute", Hash: ContentHash([]byte(test.previous))})
+			}
+			gtor := (&Command{}).New()
+			gtor.Manifest = NewManifest("p", dir)
+			gtor.Manifest.SetPrevious(previous)
+			action := &brief.Node{Type: "template", Name: "execute", Keys: map[string]string{}}
+			if test.policy != "" {
+				action.Keys["overwrite"] = test.policy
+			}
+			got, err := gtor.Overwrite(action, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got overwrite %v, want %v", got, test.want)
+			}
+			if !got && test.previous != "" && gtor.Manifest.File("execute.go") == nil {
+				t.Error("file left alone dropped from the manifest")
+			}
+		})
+	}
+}
+
+func TestValidateTemplateOverwrite(t *testing.T) {
+	tests := []struct {
+		overwrite string
+		err       bool
+	}{
+		{OverwriteAlways, false},
+		{OverwriteNever, false},
+		{OverwriteIfUnchanged, false},
+		{"sometimes", true},
+	}
+	for _, test := range tests {
+		t.Run(test.overwrite, func(t *testing.T) {
+			tmpl := &brief.Node{Type: "template", Name: "execute", Keys: map[string]string{
+				"element": "command", "file": "execute.go", "overwrite": test.overwrite,
+			}}
+			if err := ValidateTemplate(tmpl, 0); (err != nil) != test.err {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/robbyriverside/brief"
+)
+
+// PlanCommand prints what generate would do without writing anything
+type PlanCommand struct {
+	Args struct {
+		SpecFile    string `positional-arg-name:"specfile" description:"brevity specification file"`
+		Destination string `positional-arg-name:"destination" description:"where to put the project root folder"`
+	} `positional-args:"true" required:"true"`
+	SpecOptions
+	Shell string `long:"shell" description:"Shell for shell actions, like \"bash -c\" (default sh -c)" env:"BREVITY_SHELL"`
+	JSON  bool   `short:"j" long:"json" description:"Print the plan as JSON"`
+}
+
+// Execute the plan command
+func (pc *PlanCommand) Execute(args []string) error {
+	cmd := &Command{Shell: pc.Shell, plan: &Plan{}}
+	spec, err := pc.ReadSpec(cmd, pc.Args.SpecFile, pc.Args.Destination)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Generate(context.Background(), spec); err != nil {
+		return err
+	}
+	if pc.JSON {
+		return cmd.plan.WriteJSON(os.Stdout)
+	}
+	return cmd.plan.WriteText(os.Stdout)
+}
+
+// PlanStep is a template or action that fires on a spec node
+type PlanStep struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Node     string   `json:"node"`
+	File     string   `json:"file,omitempty"`
+	Dir      string   `json:"dir,omitempty"`
+	Env      []string `json:"env,omitempty"`
+	Clean    bool     `json:"clean,omitempty"`
+	Builtin  bool     `json:"builtin,omitempty"`
+	Generate bool     `json:"generate,omitempty"`
+	Phase    string   `json:"phase,omitempty"`
+	Skip     string   `json:"skip,omitempty"`
+	Command  []string `json:"command,omitempty"`
+}
+
+// ProjectPlan lists the steps for one project in the order they fire
+type ProjectPlan struct {
+	Name  string      `json:"name"`
+	Dir   string      `json:"dir"`
+	Steps []*PlanStep `json:"steps"`
+}
+
+// Plan of every project in a brevity spec
+type Plan struct {
+	Projects []*ProjectPlan `json:"projects"`
+}
+
+// AddProject starts the plan for a project
+func (plan *Plan) AddProject(name, dir string) *ProjectPlan {
+	project := &ProjectPlan{
+		Name:  name,
+		Dir:   dir,
+		Steps: []*PlanStep{},
+	}
+	plan.Projects = append(plan.Projects, project)
+	return project
+}
+
+// WriteJSON encodes the plan as JSON
+func (plan *Plan) WriteJSON(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
+
+// WriteText prints the plan for humans
+func (plan *Plan) WriteText(out io.Writer) error {
+	for _, project := range plan.Projects {
+		if _, err := fmt.Fprintf(out, "project %s -> %s\n", project.Name, project.Dir); err != nil {
+			return err
+		}
+		for _, step := range project.Steps {
+			var err error
+			switch step.Kind {
+			case "template":
+				_, err = fmt.Fprintf(out, "    template %s on %s -> %s\n", step.Name, step.Node, step.File)
+			default:
+				env := ""
+				if step.Clean {
+					env = "env -i "
+				}
+				if len(step.Env) > 0 {
+					env += strings.Join(step.Env, " ") + " "
+				}
+				run := "exec"
+				if step.Builtin {
+					run = "builtin"
+				}
+				if step.Generate {
+					run = "generate"
+				}
+				skip := ""
+				if step.Skip != "" {
+					skip = fmt.Sprintf(" (skip: %s)", step.Skip)
+				}
+				_, err = fmt.Fprintf(out, "    action %s %s on %s in %s %s: %s%s%s\n", step.Phase, step.Name, step.Node, step.Dir, run, env, strings.Join(step.Command, " "), skip)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PlanFile records the file a template would generate
+func (gtor *Generator) PlanFile(action, spec *brief.Node, dir string) error {
+	filename, err := gtor.FileName(action, spec, dir)
+	if err != nil {
+		return err
+	}
+	gtor.Plan.Steps = append(gtor.Plan.Steps, &PlanStep{
+		Kind: "template",
+		Name: action.Name,
+		Node: NodePath(spec),
+		File: filename,
+	})
+	return nil
+}
+
+// PlanAction records the command an action would execute
+func (gtor *Generator) PlanAction(action, spec *brief.Node, dir string) error {
+	args, err := gtor.ActionArgs(action, spec)
+	if err != nil {
+		return err
+	}
+	actdir, err := gtor.ActionDir(action, spec, dir)
+	if err != nil {
+		return err
+	}
+	entries, clean, err := gtor.ActionEnv(action, spec)
+	if err != nil {
+		return err
+	}
+	skip, _, err := gtor.SkipAction(action, spec, actdir)
+	if err != nil {
+		return err
+	}
+	gtor.Plan.Steps = append(gtor.Plan.Steps, &PlanStep{
+		Kind:     "action",
+		Name:     action.Name,
+		Node:     NodePath(spec),
+		Dir:      actdir,
+		Env:      entries,
+		Clean:    clean,
+		Builtin:  action.Keys["builtin"] != "",
+		Generate: action.Keys["generate"] != "",
+		Phase:    ActionPhase(action),
+		Skip:     skip,
+		Command:  args,
+	})
+	return nil
+}
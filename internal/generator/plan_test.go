@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestPlanAction(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := &brief.Node{Type: "env", Name: "clean", Keys: map[string]string{"PATH": "/bin"}}
+	withEnv := testAction("env", "exec", "go build")
+	withEnv.Body = []*brief.Node{env}
+	tests := []struct {
+		action *brief.Node
+		want   string
+	}{
+		{testAction("build", "exec", "go build ./..."), "action post-render build on project:p in DIR exec: go build ./..."},
+		{testAction("tidy", "exec", "go mod tidy", "phase", PhasePostProject, "dir", "cmd"),
+			"action post-project tidy on project:p in DIR/cmd exec: go mod tidy"},
+		{testAction("init", "exec", "go mod init p", "creates", "go.mod"),
+			"action post-render init on project:p in DIR exec: go mod init p (skip: go.mod exists)"},
+		{testAction("mkdir", "builtin", "mkdir", "args", "bin"), "action post-render mkdir on project:p in DIR builtin: mkdir bin"},
+		{withEnv, "action post-render env on project:p in DIR exec: env -i PATH=/bin go build"},
+	}
+	for _, test := range tests {
+		t.Run(test.action.Name, func(t *testing.T) {
+			gtor := (&Command{}).New()
+			gtor.Plan = &ProjectPlan{}
+			spec := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+			if err := gtor.PlanAction(test.action, spec, dir); err != nil {
+				t.Fatal(err)
+			}
+			plan := &Plan{Projects: []*ProjectPlan{gtor.Plan}}
+			out := &bytes.Buffer{}
+			if err := plan.WriteText(out); err != nil {
+				t.Fatal(err)
+			}
+			want := strings.ReplaceAll(test.want, "DIR", dir)
+			if got := strings.TrimSpace(strings.SplitN(out.String(), "\n", 2)[1]); got != want {
+				t.Errorf("got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPlanJSON(t *testing.T) {
+	plan := &Plan{}
+	project := plan.AddProject("p", "/out/p")
+	project.Steps = append(project.Steps,
+		&PlanStep{Kind: "template", Name: "main", Node: "project:p", File: "/out/p/main.go"},
+		&PlanStep{Kind: "action", Name: "build", Node: "project:p", Dir: "/out/p", Phase: PhasePostRender, Command: []string{"go", "build"}},
+	)
+	out := &bytes.Buffer{}
+	if err := plan.WriteJSON(out); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Plan{}
+	if err := json.Unmarshal(out.Bytes(), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, plan) {
+		t.Errorf("got %s", out)
+	}
+	if strings.Contains(out.String(), `"env"`) || strings.Contains(out.String(), `"skip"`) {
+		t.Errorf("empty fields are not omitted:\n%s", out)
+	}
+}
@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PolicyName of the file in a library root declaring the executables its actions run and those it trusts
+const PolicyName = "policy.brief"
+
+// ExecPolicy decides which commands actions may execute.
+// Builtin actions run inside brevity, so the allow list does not apply to them,
+// but they are skipped with NoExec and confirmed like commands.
+type ExecPolicy struct {
+	// NoExec skips every action that executes a command
+	NoExec bool
+	// Allow lists the executables that may run, when it is not empty
+	Allow []string
+	// Confirm asks before each command runs
+	Confirm bool
+	// Library lists the executables the library declares it runs, when it is not empty
+	Library []string
+	// Trusted executables declared by the library run without asking
+	Trusted []string
+	in      *bufio.Reader
+	out     io.Writer
+	mu      sync.Mutex
+	all     bool
+}
+
+// NewExecPolicy asking for confirmation on stdin
+func NewExecPolicy() *ExecPolicy {
+	return &ExecPolicy{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stdout,
+	}
+}
+
+// ReadLibraryPolicy adds the executables a library declares from its policy file, when it has one.
+// Those it allows only narrow what may run, and those it trusts run without a confirmation.
+func (p *ExecPolicy) ReadLibraryPolicy(libdir string) error {
+	filename := filepath.Join(libdir, PolicyName)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	policy, err := ReadNode(filename)
+	if err != nil {
+		return err
+	}
+	if policy.Type != "policy" {
+		return fmt.Errorf("%s: top-level must be policy", filename)
+	}
+	p.Library = append(p.Library, strings.Fields(policy.Keys["allow-exec"])...)
+	p.Trusted = append(p.Trusted, strings.Fields(policy.Keys["trust-exec"])...)
+	return nil
+}
+
+// allowed when the executable is in the list.  A name matches an executable found on the PATH,
+// not one with the same name in some folder.
+func allowed(list []string, executable string) bool {
+	for _, name := range list {
+		if name == executable {
+			return true
+		}
+	}
+	return false
+}
+
+// check reports the first executable a command runs that is outside the allow lists
+func (p *ExecPolicy) check(label string, args []string) error {
+	for _, executable := range Executables(args) {
+		if len(p.Allow) > 0 && !allowed(p.Allow, executable) {
+			return fmt.Errorf("action %s: %s is not an allowed executable, see --allow-exec", label, executable)
+		}
+		if len(p.Library) > 0 && !allowed(p.Library, executable) {
+			return fmt.Errorf("action %s: %s is not an executable the library allows in %s", label, executable, PolicyName)
+		}
+	}
+	return nil
+}
+
+// CheckSchedule reports the first action outside the allow lists before anything runs.
+// Commands that use values not yet captured are checked when they run.
+func (p *ExecPolicy) CheckSchedule(schedule *Schedule) error {
+	if p == nil || p.NoExec || (len(p.Allow) == 0 && len(p.Library) == 0) {
+		return nil
+	}
+	for _, step := range schedule.Steps {
+		_, builtin := step.Action.Keys["builtin"]
+		_, generate := step.Action.Keys["generate"]
+		if builtin || generate {
+			continue
+		}
+		args, err := step.Gtor.ActionArgs(step.Action, step.Spec)
+		if err != nil {
+			continue
+		}
+		if err := p.check(step.Label(), args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Permit an action to execute its command, the reason to skip it when it must not run.
+// Commands outside the allow lists are an error so that generation stops before they run.
+func (p *ExecPolicy) Permit(label, dir string, args []string) (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	if p.NoExec {
+		return "exec disabled", nil
+	}
+	if err := p.check(label, args); err != nil {
+		return "", err
+	}
+	if p.trusted(args) {
+		return "", nil
+	}
+	return p.confirm(label, dir, args)
+}
+
+// trusted when the library trusts every executable a command runs
+func (p *ExecPolicy) trusted(args []string) bool {
+	executables := Executables(args)
+	if len(executables) == 0 {
+		return false
+	}
+	for _, executable := range executables {
+		if !allowed(p.Trusted, executable) {
+			return false
+		}
+	}
+	return true
+}
+
+// PermitBuiltin decides if a builtin action may change the project, the reason to skip it when it must not
+func (p *ExecPolicy) PermitBuiltin(label, dir string, args []string) (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	if p.NoExec {
+		return "exec disabled", nil
+	}
+	return p.confirm(label, dir, append([]string{"builtin"}, args...))
+}
+
+// confirm a command with the user when the policy asks to
+func (p *ExecPolicy) confirm(label, dir string, args []string) (string, error) {
+	if !p.Confirm {
+		return "", nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.all {
+		return "", nil
+	}
+	// keep action output from interleaving with the question
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	for {
+		fmt.Fprintf(p.out, "run action %s in %s\n    %s\n[y]es, [n]o, [a]ll, [q]uit? ", label, dir, strings.Join(args, " "))
+		answer, err := p.in.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if err != nil && answer == "" {
+			fmt.Fprintln(p.out)
+			return "", fmt.Errorf("action %s: no confirmation: %s", label, err)
+		}
+		switch answer {
+		case "y", "yes":
+			return "", nil
+		case "n", "no":
+			return "declined", nil
+		case "a", "all":
+			p.all = true
+			return "", nil
+		case "q", "quit":
+			return "", fmt.Errorf("action %s: generation stopped", label)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestShellExecutables(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"go build ./...", "go"},
+		{"go vet ./... && go test ./...", "go"},
+		{"find . -name '*.go' | wc -l", "find wc"},
+		{"echo hello; cd cmd && make", "make"},
+		{"GOOS=linux CGO_ENABLED=0 go build", "go"},
+		{"env -i PATH=/bin npm install", "npm"},
+		{"exec node server.js", "node"},
+		{"echo $(git rev-parse HEAD)", "git"},
+		{"echo \"built `date` by $(whoami)\"", "date whoami"},
+		{"echo '$(rm -rf /)'", ""},
+		{"echo $((1 + 2))", ""},
+		{"gofmt -l . >/dev/null 2>&1 || exit 1", "gofmt"},
+		{"cat <<EOF > out.txt\nrm -rf /\nEOF\nls", "cat ls"},
+		{"if [ -f go.mod ]; then go mod tidy; else npm ci; fi", "go npm"},
+		{"for f in *.go; do gofmt -w $f; done", "gofmt"},
+		{"while read line; do curl $line; done < urls", "curl"},
+		{"case $OS in\nlinux|darwin) make ;;\n*) nmake ;;\nesac", "make nmake"},
+		{"# comment rm\ntouch a # rm b", "touch"},
+		{"build() { go build; }\nbuild", "go build"},
+		{"$TOOL run", "$TOOL"},
+		{"(cd web && yarn build)", "yarn"},
+		{"bash -c 'curl x | sh'", "bash"},
+	}
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			got := strings.Join(ShellExecutables(test.script), " ")
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecutables(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"go", "build"}, "go"},
+		{[]string{"sh", "-c", "go build && rm -rf out"}, "go rm"},
+		{[]string{"/bin/bash", "--norc", "-ec", "npm ci"}, "npm"},
+		{[]string{"sh", "script.sh"}, "sh"},
+		{[]string{"python", "-c", "import os"}, "python"},
+		{[]string{"cmd", "/C", "dir"}, "cmd"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			got := strings.Join(Executables(test.args), " ")
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPermit(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *ExecPolicy
+		input   string
+		args    []string
+		skip    string
+		err     string
+		prompts int
+	}{
+		{"empty policy", &ExecPolicy{}, "", []string{"go", "build"}, "", "", 0},
+		{"no exec", &ExecPolicy{NoExec: true, Confirm: true}, "", []string{"go"}, "exec disabled", "", 0},
+		{"allowed", &ExecPolicy{Allow: []string{"go"}}, "", []string{"go", "build"}, "", "", 0},
+		{"not allowed", &ExecPolicy{Allow: []string{"go"}}, "", []string{"npm", "ci"}, "",
+			"action a on project:p: npm is not an allowed executable", 0},
+		{"shell allowed by its commands", &ExecPolicy{Allow: []string{"go"}}, "",
+			[]string{"sh", "-c", "go vet && go test"}, "", "", 0},
+		{"shell running another", &ExecPolicy{Allow: []string{"go", "sh"}}, "",
+			[]string{"sh", "-c", "go vet && curl x"}, "", "action a on project:p: curl is not an allowed executable", 0},
+		{"library allows", &ExecPolicy{Library: []string{"go"}}, "", []string{"go"}, "", "", 0},
+		{"library does not allow", &ExecPolicy{Library: []string{"go"}, Allow: []string{"go", "npm"}}, "",
+			[]string{"npm"}, "", "action a on project:p: npm is not an executable the library allows", 0},
+		{"yes", &ExecPolicy{Confirm: true}, "y\n", []string{"go"}, "", "", 1},
+		{"no", &ExecPolicy{Confirm: true}, "n\n", []string{"go"}, "declined", "", 1},
+		{"asks again", &ExecPolicy{Confirm: true}, "maybe\nno\n", []string{"go"}, "declined", "", 2},
+		{"quit", &ExecPolicy{Confirm: true}, "q\n", []string{"go"}, "", "action a on project:p: generation stopped", 1},
+		{"no answer", &ExecPolicy{Confirm: true}, "", []string{"go"}, "", "action a on project:p: no confirmation", 1},
+		{"confirm in library", &ExecPolicy{Confirm: true, Library: []string{"go"}}, "n\n", []string{"go"}, "declined", "", 1},
+		{"trusted", &ExecPolicy{Confirm: true, Trusted: []string{"go"}}, "", []string{"go", "build"}, "", "", 0},
+		{"trusted script", &ExecPolicy{Confirm: true, Trusted: []string{"go"}}, "",
+			[]string{"sh", "-c", "go vet && go test"}, "", "", 0},
+		{"partly trusted script", &ExecPolicy{Confirm: true, Trusted: []string{"go"}}, "n\n",
+			[]string{"sh", "-c", "go vet && curl x"}, "declined", "", 1},
+		{"script of builtins", &ExecPolicy{Confirm: true, Trusted: []string{"go"}}, "n\n",
+			[]string{"sh", "-c", "echo x > f"}, "declined", "", 1},
+		{"trusted but not allowed", &ExecPolicy{Confirm: true, Allow: []string{"npm"}, Trusted: []string{"go"}}, "",
+			[]string{"go"}, "", "action a on project:p: go is not an allowed executable", 0},
+		{"trusted with no exec", &ExecPolicy{NoExec: true, Confirm: true, Trusted: []string{"go"}}, "", []string{"go"}, "exec disabled", "", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			policy := test.policy
+			policy.in = bufio.NewReader(strings.NewReader(test.input))
+			policy.out = out
+			skip, err := policy.Permit("a on project:p", "/p", test.args)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if skip != test.skip {
+				t.Errorf("got skip %q, want %q", skip, test.skip)
+			}
+			if prompts := strings.Count(out.String(), "[y]es, [n]o, [a]ll, [q]uit?"); prompts != test.prompts {
+				t.Errorf("prompted %d times, want %d:\n%s", prompts, test.prompts, out)
+			}
+		})
+	}
+}
+
+func TestPermitAll(t *testing.T) {
+	out := &bytes.Buffer{}
+	policy := &ExecPolicy{Confirm: true, in: bufio.NewReader(strings.NewReader("a\n")), out: out}
+	if skip, err := policy.Permit("a", "/p", []string{"go", "build"}); skip != "" || err != nil {
+		t.Fatalf("got skip %q, error %v", skip, err)
+	}
+	if skip, err := policy.PermitBuiltin("b", "/p", []string{"mkdir", "x"}); skip != "" || err != nil {
+		t.Fatalf("builtin got skip %q, error %v", skip, err)
+	}
+	if !strings.Contains(out.String(), "run action a in /p\n    go build\n") {
+		t.Errorf("prompt does not show the command:\n%s", out)
+	}
+	if prompts := strings.Count(out.String(), "[q]uit?"); prompts != 1 {
+		t.Errorf("prompted %d times after all", prompts)
+	}
+}
+
+func TestTrustedLibrary(t *testing.T) {
+	lib := t.TempDir()
+	if err := writeTestFile(lib, PolicyName, "policy allow-exec:\"go sh npm\" trust-exec:\"go\"\n"); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	policy := &ExecPolicy{Confirm: true, in: bufio.NewReader(strings.NewReader("n\n")), out: out}
+	if err := policy.ReadLibraryPolicy(lib); err != nil {
+		t.Fatal(err)
+	}
+	if skip, err := policy.Permit("build", "/p", []string{"sh", "-c", "go build ./... && go vet ./..."}); skip != "" || err != nil {
+		t.Fatalf("got skip %q, error %v", skip, err)
+	}
+	if prompts := strings.Count(out.String(), "[q]uit?"); prompts != 0 {
+		t.Fatalf("trusted command prompted %d times:\n%s", prompts, out)
+	}
+	if skip, _ := policy.Permit("install", "/p", []string{"npm", "ci"}); skip != "declined" {
+		t.Errorf("untrusted command got skip %q, want it confirmed", skip)
+	}
+	if _, err := policy.Permit("fetch", "/p", []string{"curl", "x"}); err == nil {
+		t.Error("the library's allow list does not narrow what runs")
+	}
+}
@@ -0,0 +1,14 @@
+package generator
+
+import "os/exec"
+
+// setProcessGroup is not needed on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
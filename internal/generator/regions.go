@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+Protected regions keep hand-written code across regeneration.  A template emits the markers
+inside whatever comment syntax the target language uses:
+
+	// brevity:begin user imports
+	// brevity:end
+
+When the file is regenerated the contents between the markers are carried over from the existing file.
+*/
+
+var (
+	regionBegin = regexp.MustCompile(`brevity:begin\s+user\s+(\S+)`)
+	regionEnd   = regexp.MustCompile(`brevity:end\b`)
+)
+
+// Region is the hand-written content of a protected region
+type Region struct {
+	ID      string
+	Content string
+}
+
+// ParseRegions finds the protected regions in file content, in order
+func ParseRegions(content string) ([]*Region, error) {
+	regions := []*Region{}
+	ids := map[string]bool{}
+	var current *Region
+	var body strings.Builder
+	for i, line := range splitLines(content) {
+		if match := regionBegin.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				return nil, fmt.Errorf("line %d: protected region %s starts inside region %s", i+1, match[1], current.ID)
+			}
+			if ids[match[1]] {
+				return nil, fmt.Errorf("line %d: duplicate protected region %s", i+1, match[1])
+			}
+			ids[match[1]] = true
+			current = &Region{ID: match[1]}
+			body.Reset()
+			continue
+		}
+		if regionEnd.MatchString(line) {
+			if current == nil {
+				return nil, fmt.Errorf("line %d: protected region end without begin", i+1)
+			}
+			current.Content = body.String()
+			regions = append(regions, current)
+			current = nil
+			continue
+		}
+		if current != nil {
+			body.WriteString(line)
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("protected region %s has no end", current.ID)
+	}
+	return regions, nil
+}
+
+// MergeRegions carries the protected regions of the existing file into the generated content.
+// Regions of the existing file that the generated content no longer has are returned as orphans.
+func MergeRegions(generated, existing string) (string, []*Region, error) {
+	if _, err := ParseRegions(generated); err != nil {
+		return "", nil, fmt.Errorf("generated %s", err)
+	}
+	regions, err := ParseRegions(existing)
+	if err != nil {
+		return "", nil, fmt.Errorf("existing %s", err)
+	}
+	if len(regions) == 0 {
+		return generated, nil, nil
+	}
+	saved := map[string]*Region{}
+	for _, region := range regions {
+		saved[region.ID] = region
+	}
+
+	used := map[string]bool{}
+	var out strings.Builder
+	var current *Region
+	for _, line := range splitLines(generated) {
+		if match := regionBegin.FindStringSubmatch(line); match != nil {
+			out.WriteString(line)
+			current = saved[match[1]]
+			if current != nil {
+				used[current.ID] = true
+				out.WriteString(current.Content)
+			}
+			continue
+		}
+		if regionEnd.MatchString(line) {
+			current = nil
+			out.WriteString(line)
+			continue
+		}
+		// keep the template's default content only when nothing was saved
+		if current == nil {
+			out.WriteString(line)
+		}
+	}
+
+	orphans := []*Region{}
+	for _, region := range regions {
+		if !used[region.ID] {
+			orphans = append(orphans, region)
+		}
+	}
+	return out.String(), orphans, nil
+}
@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		err     string
+	}{
+		{"none", "package main\n", "", ""},
+		{"one", "a\n// brevity:begin user imports\nimport \"os\"\n// brevity:end\nb\n", "imports=import \"os\"\n", ""},
+		{"two", "# brevity:begin user one\n1\n# brevity:end\n# brevity:begin user two\n# brevity:end\n", "one=1\n two=", ""},
+		{"end without begin", "a\n// brevity:end\n", "", "line 2: protected region end without begin"},
+		{"no end", "// brevity:begin user main\nx\n", "", "protected region main has no end"},
+		{"nested", "// brevity:begin user a\n// brevity:begin user b\n// brevity:end\n", "", "line 2: protected region b starts inside region a"},
+		{"duplicate", "// brevity:begin user a\n// brevity:end\n// brevity:begin user a\n// brevity:end\n", "", "line 3: duplicate protected region a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			regions, err := ParseRegions(test.content)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := []string{}
+			for _, region := range regions {
+				parts = append(parts, region.ID+"="+region.Content)
+			}
+			if got := strings.Join(parts, " "); got != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeRegions(t *testing.T) {
+	generated := "head\n// brevity:begin user body\ndefault\n// brevity:end\ntail\n"
+	tests := []struct {
+		name      string
+		generated string
+		existing  string
+		want      string
+		orphans   string
+		err       string
+	}{
+		{"kept", generated, "old head\n// brevity:begin user body\nmine\n// brevity:end\n",
+			"head\n// brevity:begin user body\nmine\n// brevity:end\ntail\n", "", ""},
+		{"default when new", generated, "old head\n", generated, "", ""},
+		{"emptied region stays empty", generated, "// brevity:begin user body\n// brevity:end\n",
+			"head\n// brevity:begin user body\n// brevity:end\ntail\n", "", ""},
+		{"orphaned", generated, "// brevity:begin user gone\nlost\n// brevity:end\n", generated, "gone", ""},
+		{"existing markers mismatched", generated, "// brevity:begin user body\nmine\n", "", "", "existing protected region body has no end"},
+		{"generated markers mismatched", "// brevity:end\n", "", "", "", "generated line 1: protected region end without begin"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, orphans, err := MergeRegions(test.generated, test.existing)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if merged != test.want {
+				t.Errorf("got\n%s\nwant\n%s", merged, test.want)
+			}
+			ids := []string{}
+			for _, orphan := range orphans {
+				ids = append(ids, orphan.ID)
+			}
+			if got := strings.Join(ids, " "); got != test.orphans {
+				t.Errorf("orphans %q want %q", got, test.orphans)
+			}
+		})
+	}
+}
+
+func TestManifestOrphansExpire(t *testing.T) {
+	dir := t.TempDir()
+	previous := NewManifest("p", dir)
+	previous.AddOrphan("kept.go", &Region{ID: "a", Content: "x\n"})
+	previous.AddOrphan("gone.go", &Region{ID: "b", Content: "y\n"})
+	if err := MakeFolder(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(dir, "kept.go", "package kept\n"); err != nil {
+		t.Fatal(err)
+	}
+	manifest := NewManifest("p", dir)
+	manifest.SetPrevious(previous)
+	manifest.AddOrphan("kept.go", &Region{ID: "a", Content: "z\n"})
+	if len(manifest.Orphans) != 1 || manifest.Orphans[0].File != "kept.go" || manifest.Orphans[0].Content != "z\n" {
+		t.Errorf("got orphans %+v", manifest.Orphans)
+	}
+}
+
+// writeTestFile writes a file below dir, making its folders
+func writeTestFile(dir, name, content string) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
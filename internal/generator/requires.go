@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/google/shlex"
+	"github.com/robbyriverside/brief"
+)
+
+// RequireTimeout limits how long a version command may run
+const RequireTimeout = 10 * time.Second
+
+// versionPattern finds the first version number in the output of a version command, like 1.21.3 in go1.21.3
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// ValidateRequire ensure correct tool node in a requires section
+func ValidateRequire(tool *brief.Node, pos int) error {
+	if tool.Type != "tool" || tool.Name == "" {
+		return fmt.Errorf("requires entry %d must be a named tool", pos)
+	}
+	constraint, ok := tool.Keys["constraint"]
+	if !ok {
+		return nil
+	}
+	if _, ok := tool.Keys["version"]; !ok {
+		return fmt.Errorf("tool:%q constraint requires a version command", tool.Name)
+	}
+	if _, err := semver.NewConstraint(constraint); err != nil {
+		return fmt.Errorf("tool:%q constraint %q: %s", tool.Name, constraint, err)
+	}
+	return nil
+}
+
+// Preflight remembers the tools checked during a run, so each is checked once
+type Preflight struct {
+	mu      sync.Mutex
+	results map[string]error
+}
+
+// NewPreflight for a run
+func NewPreflight() *Preflight {
+	return &Preflight{results: map[string]error{}}
+}
+
+// CheckRequires verifies the tools required by the section generators are installed at a suitable version
+func (gtor *Generator) CheckRequires(ctx context.Context) error {
+	for _, tool := range gtor.Requires {
+		if err := gtor.Preflight.Check(ctx, tool, gtor.Policy); err != nil {
+			return fmt.Errorf("%s requires %s", gtor.Sources[tool], err)
+		}
+	}
+	return nil
+}
+
+// Check one required tool, reusing the result of an identical check
+func (pf *Preflight) Check(ctx context.Context, tool *brief.Node, policy *ExecPolicy) error {
+	key := strings.Join([]string{tool.Name, tool.Keys["version"], tool.Keys["constraint"]}, "\x00")
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if err, ok := pf.results[key]; ok {
+		return err
+	}
+	err := checkTool(ctx, tool, policy)
+	pf.results[key] = err
+	return err
+}
+
+// checkTool is on the PATH and that its version command reports a version meeting the constraint.
+// Tools are only checked when generating, as validate, plan, diff and render run nothing and have no policy.
+func checkTool(ctx context.Context, tool *brief.Node, policy *ExecPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if _, err := exec.LookPath(tool.Name); err != nil {
+		return fmt.Errorf("%s: not found on PATH", tool.Name)
+	}
+	command, ok := tool.Keys["version"]
+	if !ok {
+		return nil
+	}
+	args, err := shlex.Split(command)
+	if err != nil || len(args) == 0 {
+		return fmt.Errorf("%s: invalid version command %q", tool.Name, command)
+	}
+	// version commands come from the library, so they follow the exec policy
+	skip, err := policy.Permit(fmt.Sprintf("requires %s", tool.Name), ".", args)
+	if err != nil {
+		return err
+	}
+	if skip != "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, RequireTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s failed: %s", tool.Name, command, err)
+	}
+	constraint, ok := tool.Keys["constraint"]
+	if !ok {
+		return nil
+	}
+	found := versionPattern.FindString(string(out))
+	if found == "" {
+		return fmt.Errorf("%s: no version in output of %s", tool.Name, command)
+	}
+	version, err := semver.NewVersion(found)
+	if err != nil {
+		return fmt.Errorf("%s: version %s: %s", tool.Name, found, err)
+	}
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return err
+	}
+	if !constraints.Check(version) {
+		return fmt.Errorf("%s %s, found %s", tool.Name, constraint, version)
+	}
+	return nil
+}
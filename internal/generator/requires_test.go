@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestCheckTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    string
+		version string
+		policy  *ExecPolicy
+		err     string
+	}{
+		{"on the path", "sh", "", &ExecPolicy{}, ""},
+		{"not on the path", "no-such-tool-here", "", &ExecPolicy{}, "no-such-tool-here: not found on PATH"},
+		{"not looked for without a policy", "no-such-tool-here", "", nil, ""},
+		{"not on the path with no exec", "no-such-tool-here", "", &ExecPolicy{NoExec: true}, "no-such-tool-here: not found on PATH"},
+		{"version not run without a policy", "sh", "sh -c 'exit 1'", nil, ""},
+		{"version skipped with no exec", "sh", "sh -c 'exit 1'", &ExecPolicy{NoExec: true}, ""},
+		{"version meets constraint", "sh", "sh -c 'echo go1.21.3'", &ExecPolicy{}, ""},
+		{"version too old", "sh", "sh -c 'echo version 1.16'", &ExecPolicy{}, "sh >= 1.18, found 1.16.0"},
+		{"version not allowed", "sh", "sh -c 'curl x'", &ExecPolicy{Allow: []string{"go"}},
+			"action requires sh: curl is not an allowed executable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tool := &brief.Node{Type: "tool", Name: test.tool, Keys: map[string]string{}}
+			if test.version != "" {
+				tool.Keys["version"] = test.version
+				tool.Keys["constraint"] = ">= 1.18"
+			}
+			err := checkTool(context.Background(), tool, test.policy)
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/robbyriverside/brief"
+)
+
+// Phases of generating a project, in the order their actions run
+const (
+	PhasePreRender   = "pre-render"
+	PhasePostRender  = "post-render"
+	PhasePostProject = "post-project"
+	PhaseFinalize    = "finalize"
+)
+
+// Phases in the order they run
+var Phases = []string{PhasePreRender, PhasePostRender, PhasePostProject, PhaseFinalize}
+
+// PhaseIndex of a phase in Phases, -1 when it is not a phase
+func PhaseIndex(phase string) int {
+	for i, p := range Phases {
+		if p == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// ActionPhase of an action from its phase key.  Without one, actions that capture
+// run pre-render so templates can use their values, and the rest post-render.
+func ActionPhase(action *brief.Node) string {
+	phase, ok := action.Keys["phase"]
+	if ok {
+		return phase
+	}
+	if _, ok := action.Keys["capture"]; ok {
+		return PhasePreRender
+	}
+	return PhasePostRender
+}
+
+// ActionAfter names the actions that must run before this one, from its after key
+func ActionAfter(action *brief.Node) []string {
+	return strings.FieldsFunc(action.Keys["after"], func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// ActionStep runs one action on one spec node
+type ActionStep struct {
+	Gtor   *Generator
+	Action *brief.Node
+	Spec   *brief.Node
+	Dir    string
+	Phase  string
+	after  []*ActionStep
+}
+
+// Label of the step for messages
+func (step *ActionStep) Label() string {
+	return fmt.Sprintf("%s on %s", step.Action.Name, NodePath(step.Spec))
+}
+
+// Run the action of this step
+func (step *ActionStep) Run(ctx context.Context) error {
+	return step.Gtor.RunAction(ctx, step.Action, step.Spec, step.Dir)
+}
+
+// Schedule orders the actions of a project by phase and by their after lists.
+// Steps without an ordering between them keep the order of the spec walk.
+type Schedule struct {
+	Project    string
+	Jobs       int
+	Manifest   *Manifest
+	State      *State
+	Generators []*Generator
+	Steps      []*ActionStep
+	defined    map[string]bool
+}
+
+// NewSchedule for the actions of a project
+func NewSchedule(project string) *Schedule {
+	return &Schedule{
+		Project: project,
+		defined: map[string]bool{},
+	}
+}
+
+// Define the actions a generator knows, so after can name them even when they have no steps
+func (s *Schedule) Define(gtor *Generator) {
+	for _, agenda := range gtor.Catalog {
+		for _, action := range agenda.Actions.List {
+			s.defined[action.Name] = true
+		}
+	}
+}
+
+// AddSection collects the actions of a compiled section and of its project.
+// They are collected as the walk unwinds, so without phases or after lists
+// the actions of deeper nodes run first.
+func (s *Schedule) AddSection(gtor *Generator, project, section *brief.Node, dir string) {
+	s.Generators = append(s.Generators, gtor)
+	s.Define(gtor)
+	s.Collect(gtor, section, dir)
+	s.Add(gtor, project, dir)
+}
+
+// Collect the actions of a node hierarchy as it is walked back up the tree
+func (s *Schedule) Collect(gtor *Generator, node *brief.Node, dir string) {
+	for _, subnode := range node.Body {
+		s.Collect(gtor, subnode, dir)
+	}
+	s.Add(gtor, node, dir)
+}
+
+// Add a step for every action on this spec node
+func (s *Schedule) Add(gtor *Generator, spec *brief.Node, dir string) {
+	agenda, ok := gtor.Catalog[spec.Type]
+	if !ok {
+		return
+	}
+	for _, action := range agenda.Actions.List {
+		s.Steps = append(s.Steps, &ActionStep{
+			Gtor:   gtor,
+			Action: action,
+			Spec:   spec,
+			Dir:    dir,
+			Phase:  ActionPhase(action),
+		})
+	}
+}
+
+// Order the steps topologically, phase by phase
+func (s *Schedule) Order() error {
+	byName := map[string][]*ActionStep{}
+	byCapture := map[string][]*ActionStep{}
+	for _, step := range s.Steps {
+		byName[step.Action.Name] = append(byName[step.Action.Name], step)
+		if capture, ok := step.Action.Keys["capture"]; ok {
+			byCapture[capture] = append(byCapture[capture], step)
+		}
+	}
+	for _, step := range s.Steps {
+		step.after = nil
+		// actions capturing the same name run in spec order, so the value left is the last one
+		if capture, ok := step.Action.Keys["capture"]; ok {
+			for _, before := range byCapture[capture] {
+				if before == step {
+					break
+				}
+				if before.Phase == step.Phase {
+					step.after = append(step.after, before)
+				}
+			}
+		}
+		// an action using a captured value runs after the actions capturing it
+		for _, name := range ActionCaptures(step.Action) {
+			for _, before := range byCapture[name] {
+				switch {
+				case PhaseIndex(before.Phase) > PhaseIndex(step.Phase):
+					return fmt.Errorf("action %s in phase %s uses %s captured by action %s in phase %s",
+						step.Action.Name, step.Phase, name, before.Action.Name, before.Phase)
+				case before.Phase == step.Phase && before != step:
+					step.after = append(step.after, before)
+				}
+			}
+		}
+		for _, name := range ActionAfter(step.Action) {
+			if !s.defined[name] {
+				return fmt.Errorf("action %s runs after unknown action %s", step.Action.Name, name)
+			}
+			for _, before := range byName[name] {
+				switch {
+				case PhaseIndex(before.Phase) > PhaseIndex(step.Phase):
+					return fmt.Errorf("action %s in phase %s cannot run after action %s in phase %s",
+						step.Action.Name, step.Phase, name, before.Phase)
+				case before.Phase == step.Phase:
+					step.after = append(step.after, before)
+				}
+			}
+		}
+	}
+
+	ordered := make([]*ActionStep, 0, len(s.Steps))
+	for _, phase := range Phases {
+		remaining := []*ActionStep{}
+		for _, step := range s.Steps {
+			if step.Phase == phase {
+				remaining = append(remaining, step)
+			}
+		}
+		done := map[*ActionStep]bool{}
+		for len(remaining) > 0 {
+			next := -1
+			for i, step := range remaining {
+				if stepReady(step, done) {
+					next = i
+					break
+				}
+			}
+			if next < 0 {
+				return fmt.Errorf("action cycle in phase %s: %s", phase, stepCycle(remaining, done))
+			}
+			done[remaining[next]] = true
+			ordered = append(ordered, remaining[next])
+			remaining = append(remaining[:next], remaining[next+1:]...)
+		}
+	}
+	s.Steps = ordered
+	return nil
+}
+
+func stepReady(step *ActionStep, done map[*ActionStep]bool) bool {
+	for _, before := range step.after {
+		if !done[before] {
+			return false
+		}
+	}
+	return true
+}
+
+// stepCycle follows unfinished dependencies until one repeats, every remaining step has one
+func stepCycle(remaining []*ActionStep, done map[*ActionStep]bool) string {
+	seen := map[*ActionStep]int{}
+	path := []*ActionStep{}
+	step := remaining[0]
+	for {
+		if at, ok := seen[step]; ok {
+			path = append(path[at:], step)
+			break
+		}
+		seen[step] = len(path)
+		path = append(path, step)
+		for _, before := range step.after {
+			if !done[before] {
+				step = before
+				break
+			}
+		}
+	}
+	labels := []string{}
+	for _, step := range path {
+		labels = append(labels, step.Label())
+	}
+	return strings.Join(labels, " after ")
+}
+
+// Phase steps in the order they run
+func (s *Schedule) Phase(phase string) []*ActionStep {
+	steps := []*ActionStep{}
+	for _, step := range s.Steps {
+		if step.Phase == phase {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Run the steps of the given phases in order.
+// With more than one job, steps of a phase run as soon as the steps they are after finish.
+func (s *Schedule) Run(ctx context.Context, phases ...string) error {
+	for _, phase := range phases {
+		steps := s.Phase(phase)
+		if s.Jobs > 1 {
+			if err := s.runParallel(ctx, steps); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, step := range steps {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := step.Run(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type stepResult struct {
+	step *ActionStep
+	err  error
+}
+
+// batchParent is the key of the context a parallel batch of steps was started from
+type batchParent struct{}
+
+// Interrupted when the run itself was stopped, not just a batch of steps
+// cancelled because a step beside them failed
+func Interrupted(ctx context.Context) bool {
+	for {
+		parent, ok := ctx.Value(batchParent{}).(context.Context)
+		if !ok {
+			return ctx.Err() != nil
+		}
+		ctx = parent
+	}
+}
+
+// runParallel runs up to Jobs steps at once, stopping the others at the first failure
+func (s *Schedule) runParallel(parent context.Context, steps []*ActionStep) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	ctx = context.WithValue(ctx, batchParent{}, parent)
+	results := make(chan stepResult)
+	started := map[*ActionStep]bool{}
+	done := map[*ActionStep]bool{}
+	running := 0
+	var failed error
+	for {
+		if failed == nil {
+			failed = ctx.Err()
+		}
+		// start ready steps in schedule order until every job is busy
+		for _, step := range steps {
+			if failed != nil || running >= s.Jobs {
+				break
+			}
+			if started[step] || !stepReady(step, done) {
+				continue
+			}
+			started[step] = true
+			running++
+			go func(step *ActionStep) {
+				results <- stepResult{step, step.Run(ctx)}
+			}(step)
+		}
+		if running == 0 {
+			return failed
+		}
+		result := <-results
+		running--
+		done[result.step] = true
+		if result.err != nil && failed == nil {
+			failed = result.err
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robbyriverside/brief"
+)
+
+// testAction on the project element, keys are given as key, value pairs
+func testAction(name string, keys ...string) *brief.Node {
+	action := &brief.Node{Type: "action", Name: name, Keys: map[string]string{"element": "project", "exec": "true"}}
+	for i := 0; i+1 < len(keys); i += 2 {
+		action.Keys[keys[i]] = keys[i+1]
+	}
+	return action
+}
+
+// testSchedule of the actions on a project node, ordered
+func testSchedule(actions ...*brief.Node) (*Schedule, error) {
+	gtor := &Generator{Catalog: Catalog{}}
+	for _, action := range actions {
+		gtor.Catalog.Add("project").AddAction(action)
+	}
+	project := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+	schedule := NewSchedule("p")
+	schedule.Define(gtor)
+	schedule.Add(gtor, project, "")
+	return schedule, schedule.Order()
+}
+
+func TestScheduleOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []*brief.Node
+		want    string
+		err     string
+	}{
+		{"spec order", []*brief.Node{testAction("a"), testAction("b"), testAction("c")},
+			"post-render:a post-render:b post-render:c", ""},
+		{"phases", []*brief.Node{
+			testAction("done", "phase", PhaseFinalize),
+			testAction("vet", "phase", PhasePostProject),
+			testAction("build"),
+			testAction("init", "phase", PhasePreRender),
+		}, "pre-render:init post-render:build post-project:vet finalize:done", ""},
+		{"after", []*brief.Node{testAction("build", "after", "tidy"), testAction("tidy")},
+			"post-render:tidy post-render:build", ""},
+		{"after list", []*brief.Node{testAction("c", "after", "a, b"), testAction("b", "after", "a"), testAction("a")},
+			"post-render:a post-render:b post-render:c", ""},
+		{"after an earlier phase", []*brief.Node{testAction("build", "after", "init"), testAction("init", "phase", PhasePreRender)},
+			"pre-render:init post-render:build", ""},
+		{"after a later phase", []*brief.Node{testAction("init", "phase", PhasePreRender, "after", "build"), testAction("build")},
+			"", "action init in phase pre-render cannot run after action build in phase post-render"},
+		{"after unknown", []*brief.Node{testAction("build", "after", "nothing")},
+			"", "action build runs after unknown action nothing"},
+		{"cycle", []*brief.Node{testAction("a", "after", "b"), testAction("b", "after", "a")},
+			"", "action cycle in phase post-render: a on project:p after b on project:p after a on project:p"},
+		{"cycle of three", []*brief.Node{testAction("a", "after", "c"), testAction("b", "after", "a"), testAction("c", "after", "b"), testAction("d")},
+			"", "action cycle in phase post-render: a on project:p after c on project:p after b on project:p after a on project:p"},
+		{"self cycle", []*brief.Node{testAction("a", "after", "a")},
+			"", "action cycle in phase post-render: a on project:p after a on project:p"},
+		{"capture runs pre-render", []*brief.Node{testAction("build"), testAction("version", "capture", "v")},
+			"pre-render:version post-render:build", ""},
+		{"uses a capture", []*brief.Node{
+			testAction("report", "phase", PhasePreRender, "exec", "echo {{ captured `v` }}"),
+			testAction("version", "capture", "v"),
+		}, "pre-render:version pre-render:report", ""},
+		{"capture in a later phase", []*brief.Node{
+			testAction("report", "phase", PhasePreRender, "exec", "echo {{ captured `v` }}"),
+			testAction("version", "capture", "v", "phase", PhasePostRender),
+		}, "", "action report in phase pre-render uses v captured by action version in phase post-render"},
+		{"captures of a name in order", []*brief.Node{
+			testAction("first", "capture", "v", "after", "tool"),
+			testAction("second", "capture", "v"),
+			testAction("tool", "phase", PhasePreRender),
+		}, "pre-render:tool pre-render:first pre-render:second", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := testSchedule(test.actions...)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps := []string{}
+			for _, step := range schedule.Steps {
+				steps = append(steps, step.Phase+":"+step.Action.Name)
+			}
+			if got := strings.Join(steps, " "); got != test.want {
+				t.Errorf("got %s want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// runSchedule runs the post-render actions on a project node in dir with up to jobs at once
+func runSchedule(ctx context.Context, dir string, jobs int, actions ...*brief.Node) (*Generator, error) {
+	gtor := (&Command{Jobs: jobs}).New()
+	gtor.Manifest = NewManifest("p", dir)
+	for _, action := range actions {
+		gtor.Catalog.Add("project").AddAction(action)
+	}
+	project := &brief.Node{Type: "project", Name: "p", Keys: map[string]string{}}
+	schedule := NewSchedule("p")
+	schedule.Jobs = jobs
+	schedule.Define(gtor)
+	schedule.Add(gtor, project, dir)
+	if err := schedule.Order(); err != nil {
+		return gtor, err
+	}
+	return gtor, schedule.Run(ctx, PhasePostRender)
+}
+
+func TestRunParallel(t *testing.T) {
+	sleep := func(name string, keys ...string) *brief.Node {
+		return testAction(name, append([]string{"exec", "sh -c 'sleep 0.2; echo " + name + " >> ran'"}, keys...)...)
+	}
+	tests := []struct {
+		name    string
+		jobs    int
+		actions []*brief.Node
+		order   string
+		least   time.Duration
+		most    time.Duration
+	}{
+		{"one job", 1, []*brief.Node{sleep("a"), sleep("b"), sleep("c")}, "a b c", 600 * time.Millisecond, 0},
+		{"jobs limit", 2, []*brief.Node{sleep("a"), sleep("b"), sleep("c")}, "", 400 * time.Millisecond, 0},
+		{"all at once", 3, []*brief.Node{sleep("a"), sleep("b"), sleep("c")}, "", 200 * time.Millisecond, 550 * time.Millisecond},
+		{"after waits", 3, []*brief.Node{sleep("c", "after", "b"), sleep("b", "after", "a"), sleep("a")},
+			"a b c", 600 * time.Millisecond, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			start := time.Now()
+			if _, err := runSchedule(context.Background(), dir, test.jobs, test.actions...); err != nil {
+				t.Fatal(err)
+			}
+			took := time.Since(start)
+			if took < test.least || (test.most > 0 && took > test.most) {
+				t.Errorf("took %s, want between %s and %s", took, test.least, test.most)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, "ran"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ran := strings.Fields(string(data))
+			if len(ran) != len(test.actions) {
+				t.Errorf("ran %s", ran)
+			}
+			if test.order != "" && strings.Join(ran, " ") != test.order {
+				t.Errorf("ran %s, want %s", ran, test.order)
+			}
+		})
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	run, stop := context.WithCancel(context.Background())
+	defer stop()
+	batch, cancel := context.WithCancel(run)
+	batch = context.WithValue(batch, batchParent{}, run)
+	nested, cancelNested := context.WithCancel(batch)
+	defer cancelNested()
+	inner, cancelInner := context.WithCancel(nested)
+	inner = context.WithValue(inner, batchParent{}, nested)
+	defer cancelInner()
+	if Interrupted(inner) || Interrupted(batch) {
+		t.Fatal("interrupted before anything stopped")
+	}
+	cancel()
+	if Interrupted(batch) || Interrupted(inner) {
+		t.Error("a cancelled batch reads as an interrupt")
+	}
+	stop()
+	if !Interrupted(batch) || !Interrupted(inner) || !Interrupted(run) {
+		t.Error("an interrupted run is not reported")
+	}
+}
+
+func TestRunParallelCancel(t *testing.T) {
+	ctx := context.Background()
+	gtor, err := runSchedule(ctx, t.TempDir(), 2,
+		testAction("slow", "exec", "sleep 5"),
+		testAction("broken", "exec", "sh -c 'sleep 0.2; exit 2'"))
+	if err == nil || !strings.Contains(err.Error(), "exit status 2") {
+		t.Fatalf("got error %v, want the failed action's", err)
+	}
+	if Interrupted(ctx) {
+		t.Error("a failed action reads as an interrupt")
+	}
+	errors := map[string]string{}
+	for _, record := range gtor.Manifest.Actions {
+		errors[record.Action] = record.Error
+	}
+	if want := "action slow on project:p cancelled after another action failed"; errors["slow"] != want {
+		t.Errorf("got slow error %q, want %q", errors["slow"], want)
+	}
+}
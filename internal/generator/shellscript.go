@@ -0,0 +1,300 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// shells whose -c scripts are checked by the programs they run
+var scriptShells = wordSet(`ash bash dash ksh sh zsh`)
+
+var (
+	// words that start a command without running anything
+	shellKeywords = wordSet(`! { } if then else elif fi do done while until esac time`)
+	// builtins that run no other program
+	shellBuiltins = wordSet(`: [ [[ ]] alias break cd continue echo exit export false getopts printf pwd read
+		readonly return set shift test true type ulimit umask unalias unset wait`)
+	// wrappers that run the command following them
+	shellPrefixes = wordSet(`command env exec nohup`)
+)
+
+// Executables a command line runs.  A script given to a sh like shell with -c is read for the
+// programs its commands run, and any other command runs its first argument.
+func Executables(args []string) []string {
+	shell := strings.TrimSuffix(filepath.Base(args[0]), ".exe")
+	if scriptShells[shell] {
+		for i := 1; i+1 < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+			if !strings.HasPrefix(args[i], "--") && strings.Contains(args[i], "c") {
+				return ShellExecutables(args[i+1])
+			}
+		}
+	}
+	return args[:1]
+}
+
+// ShellExecutables names the programs a shell script runs, in order, from the first word of
+// each command, including those substituted with $(...) or backquotes.  Builtins that run
+// nothing else are left out, and a program the script computes, like $tool, is named as written.
+func ShellExecutables(script string) []string {
+	executables := []string{}
+	seen := map[string]bool{}
+	pattern := false
+	for _, command := range shellCommands(script) {
+		if pattern {
+			// the patterns of a case end with ), or | before another pattern
+			if (len(command.words) > 0 && command.words[0] == "esac") || command.end == ")" {
+				pattern = false
+			}
+			continue
+		}
+		name := commandName(command.words)
+		switch {
+		case name == "case":
+			pattern = command.end != ")"
+		case command.end == "(" && len(command.words) == 1:
+			// a function definition, f() { ... }
+		case name == "" || name == "for" || name == "select" || name == "function" || shellBuiltins[name]:
+		case !seen[name]:
+			seen[name] = true
+			executables = append(executables, name)
+		}
+		if command.end == ";;" {
+			pattern = true
+		}
+	}
+	return executables
+}
+
+// shellCommand is the words of a command in a script and the operator ending it
+type shellCommand struct {
+	words []string
+	end   string
+}
+
+// commandName is the program a command runs, after keywords, assignments and wrappers like env
+func commandName(words []string) string {
+	for len(words) > 0 {
+		word := words[0]
+		switch {
+		case shellKeywords[word] || shellAssignment(word):
+		case shellPrefixes[word]:
+			// options of the wrapper come before its command
+			for len(words) > 1 && strings.HasPrefix(words[1], "-") {
+				words = words[1:]
+			}
+		default:
+			return word
+		}
+		words = words[1:]
+	}
+	return ""
+}
+
+// shellAssignment is a word setting a variable, like GOOS=linux
+func shellAssignment(word string) bool {
+	eq := strings.IndexByte(word, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i, c := range word[:eq] {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// shellCommands splits a script into its commands, roughly as sh reads it.
+// The commands substituted in a word come before the command using it.
+func shellCommands(script string) []shellCommand {
+	commands := []shellCommand{}
+	words := []string{}
+	var word strings.Builder
+	inWord, redirect := false, false
+	heredocs := []string{}
+	endWord := func() {
+		if inWord && !redirect {
+			words = append(words, word.String())
+		}
+		if inWord {
+			redirect = false
+		}
+		word.Reset()
+		inWord = false
+	}
+	endCommand := func(end string) {
+		endWord()
+		commands = append(commands, shellCommand{words: words, end: end})
+		words = []string{}
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\\':
+			i++
+			if i < len(script) && script[i] != '\n' {
+				word.WriteByte(script[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := closeIndex(script, i+1, "'")
+			word.WriteString(script[i+1 : end])
+			inWord = true
+			i = end
+		case c == '"':
+			end := i + 1
+			for end < len(script) && script[end] != '"' {
+				if script[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(script) {
+				end = len(script)
+			}
+			// commands substituted inside double quotes run too
+			commands = append(commands, substitutedCommands(script[i+1:end])...)
+			word.WriteString(script[i+1 : end])
+			inWord = true
+			i = end
+		case c == '`':
+			end := closeIndex(script, i+1, "`")
+			commands = append(commands, shellCommands(script[i+1:end])...)
+			word.WriteString(script[i:end])
+			inWord = true
+			i = end
+		case strings.HasPrefix(script[i:], "$(("):
+			end := closeIndex(script, i+3, "))")
+			word.WriteString(script[i:end])
+			inWord = true
+			i = end + 1
+		case strings.HasPrefix(script[i:], "$("):
+			end := closeParen(script, i+1)
+			commands = append(commands, shellCommands(script[i+2:end])...)
+			word.WriteString(script[i:end])
+			inWord = true
+			i = end
+		case c == '#' && !inWord:
+			for i+1 < len(script) && script[i+1] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(script[i:], "<<") && !strings.HasPrefix(script[i:], "<<<"):
+			// the lines of a here document follow the command
+			endWord()
+			i += 2
+			if i < len(script) && script[i] == '-' {
+				i++
+			}
+			for i < len(script) && (script[i] == ' ' || script[i] == '\t') {
+				i++
+			}
+			start := i
+			for i < len(script) && !strings.ContainsRune(" \t\n;&|<>()", rune(script[i])) {
+				i++
+			}
+			heredocs = append(heredocs, strings.Trim(script[start:i], `'"\`))
+			i--
+		case c == '>' || c == '<':
+			// the file of a redirection is not a word of the command, nor is the descriptor before it
+			if inWord && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			endWord()
+			for i+1 < len(script) && strings.IndexByte("<>&|", script[i+1]) >= 0 {
+				i++
+			}
+			redirect = true
+		case strings.HasPrefix(script[i:], ";;"):
+			endCommand(";;")
+			i++
+		case strings.IndexByte(";&|()\n", c) >= 0:
+			endCommand(string(c))
+			if c == '\n' {
+				i = skipHeredocs(script, i, heredocs)
+				heredocs = nil
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand("")
+	return commands
+}
+
+// substitutedCommands are the commands of the $(...) and backquoted substitutions in a double quoted string
+func substitutedCommands(text string) []shellCommand {
+	commands := []shellCommand{}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case text[i] == '`':
+			end := closeIndex(text, i+1, "`")
+			commands = append(commands, shellCommands(text[i+1:end])...)
+			i = end
+		case strings.HasPrefix(text[i:], "$(("):
+			i = closeIndex(text, i+3, "))") + 1
+		case strings.HasPrefix(text[i:], "$("):
+			end := closeParen(text, i+1)
+			commands = append(commands, shellCommands(text[i+2:end])...)
+			i = end
+		}
+	}
+	return commands
+}
+
+// closeIndex of the next close string from start, or the end of the script without one
+func closeIndex(script string, start int, close string) int {
+	if start > len(script) {
+		return len(script)
+	}
+	end := strings.Index(script[start:], close)
+	if end < 0 {
+		return len(script)
+	}
+	return start + end
+}
+
+// closeParen finds the parenthesis closing the one at open, or the end of the script without one
+func closeParen(script string, open int) int {
+	depth := 0
+	for i := open; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			i++
+		case '\'':
+			i = closeIndex(script, i+1, "'")
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(script)
+}
+
+// skipHeredocs skips the lines of the here documents after the newline at i, returning the last newline skipped
+func skipHeredocs(script string, i int, delims []string) int {
+	for _, delim := range delims {
+		for i+1 < len(script) {
+			end := strings.IndexByte(script[i+1:], '\n')
+			if end < 0 {
+				return len(script)
+			}
+			line := script[i+1 : i+1+end]
+			i += end + 1
+			if strings.TrimLeft(line, "\t") == delim {
+				break
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Staging renders a project beside its destination folder so that nothing is written
+// until every template succeeds.  Files replaced when the stage is committed, and the files
+// brevity tracks which actions may change in place, are backed up so that a failure
+// can roll the project back.
+type Staging struct {
+	Dir      string
+	stageDir string
+	fresh    bool
+	existing map[string]bool
+	saved    map[string]bool
+}
+
+// NewStaging creates the staging folder for a project folder, noting the files it has
+// before any action runs so that a rollback removes every file added since
+func NewStaging(dir string) (*Staging, error) {
+	return newStaging(dir, filepath.Dir(dir))
+}
+
+// NewDiffStaging stages a project under the temp folder, leaving its destination untouched
+func NewDiffStaging(dir string) (*Staging, error) {
+	return newStaging(dir, os.TempDir())
+}
+
+func newStaging(dir, parent string) (*Staging, error) {
+	_, err := os.Stat(dir)
+	fresh := os.IsNotExist(err)
+	if err != nil && !fresh {
+		return nil, err
+	}
+	existing, err := listTree(dir)
+	if err != nil {
+		return nil, err
+	}
+	stageDir, err := ioutil.TempDir(parent, fmt.Sprintf(".%s.brevity-", filepath.Base(dir)))
+	if err != nil {
+		return nil, err
+	}
+	return &Staging{
+		Dir:      dir,
+		stageDir: stageDir,
+		fresh:    fresh,
+		existing: existing,
+		saved:    map[string]bool{},
+	}, nil
+}
+
+func (st *Staging) filesDir() string {
+	return filepath.Join(st.stageDir, "files")
+}
+
+func (st *Staging) backupDir() string {
+	return filepath.Join(st.stageDir, "backup")
+}
+
+// Path where a file of the project folder is staged
+func (st *Staging) Path(filename string) (string, error) {
+	rel, err := filepath.Rel(st.Dir, filename)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside project %s", filename, st.Dir)
+	}
+	return filepath.Join(st.filesDir(), rel), nil
+}
+
+// Backup copies files of the project folder, relative to it, so that a rollback restores them
+// even when an action changes them in place.  Files that do not exist are ignored.
+func (st *Staging) Backup(names []string) error {
+	for _, rel := range names {
+		if st.saved[rel] {
+			continue
+		}
+		final := filepath.Join(st.Dir, rel)
+		info, err := os.Lstat(final)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if err := copyFile(final, filepath.Join(st.backupDir(), rel), info.Mode().Perm()); err != nil {
+			return err
+		}
+		st.saved[rel] = true
+	}
+	return nil
+}
+
+// Commit moves the staged files into the project folder, backing up the files they replace
+func (st *Staging) Commit() error {
+	if err := os.MkdirAll(st.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	staged, err := listTree(st.filesDir())
+	if err != nil {
+		return err
+	}
+	for rel := range staged {
+		final := filepath.Join(st.Dir, rel)
+		_, err := os.Lstat(final)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		case st.saved[rel] || !st.existing[rel]:
+			// already backed up, or added by an action since the stage began
+			if err := os.Remove(final); err != nil {
+				return err
+			}
+		default:
+			backup := filepath.Join(st.backupDir(), rel)
+			if err := os.MkdirAll(filepath.Dir(backup), os.ModePerm); err != nil {
+				return err
+			}
+			if err := os.Rename(final, backup); err != nil {
+				return err
+			}
+			st.saved[rel] = true
+		}
+		if err := os.MkdirAll(filepath.Dir(final), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(st.filesDir(), rel), final); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback the project folder to where it was when the stage began: files added since are
+// removed and the files backed up are restored.  Untracked files an action changed in place are not restored.
+func (st *Staging) Rollback() error {
+	if st.fresh {
+		return os.RemoveAll(st.Dir)
+	}
+	current, err := listTree(st.Dir)
+	if err != nil {
+		return err
+	}
+	for rel := range current {
+		if !st.existing[rel] {
+			if err := os.Remove(filepath.Join(st.Dir, rel)); err != nil {
+				return err
+			}
+		}
+	}
+	for rel := range st.saved {
+		final := filepath.Join(st.Dir, rel)
+		if err := os.MkdirAll(filepath.Dir(final), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Remove(final); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(filepath.Join(st.backupDir(), rel), final); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cleanup removes the staging folder
+func (st *Staging) Cleanup() error {
+	return os.RemoveAll(st.stageDir)
+}
+
+// listTree finds the files under a folder relative to it, an empty set when there is no folder
+func listTree(dir string) (map[string]bool, error) {
+	files := map[string]bool{}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return files, nil
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = true
+		return nil
+	})
+	return files, err
+}
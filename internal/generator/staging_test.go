@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readTree reads every file below dir as name=content, sorted
+func readTree(t *testing.T, dir string) string {
+	t.Helper()
+	files, err := listTree(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []string{}
+	for rel := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, filepath.ToSlash(rel)+"="+string(data))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, " ")
+}
+
+func TestStagingRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	for name, content := range map[string]string{"go.mod": "module a", "main.go": "old main", "notes.txt": "mine"} {
+		if err := writeTestFile(dir, name, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := readTree(t, dir)
+	stage, err := NewStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+	if err := stage.Backup([]string{"go.mod", "main.go", "missing.go"}); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := stage.Path(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(filepath.Dir(staged), "main.go", "new main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// an action changes a tracked file in place and adds another
+	if err := writeTestFile(dir, "go.mod", "module b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(dir, "go.sum", "sums"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dir); got != "go.mod=module b go.sum=sums main.go=new main notes.txt=mine" {
+		t.Fatalf("after commit got %s", got)
+	}
+	if err := stage.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dir); got != before {
+		t.Errorf("after rollback got %s want %s", got, before)
+	}
+}
+
+func TestStagingPathOutsideProject(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	stage, err := NewStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+	if _, err := stage.Path(filepath.Join(dir, "..", "other", "x")); err == nil {
+		t.Error("file outside the project was staged")
+	}
+}
+
+func TestStagingRollbackBeforeCommit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	if err := writeTestFile(dir, "go.mod", "module a"); err != nil {
+		t.Fatal(err)
+	}
+	stage, err := NewStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+	if err := stage.Backup([]string{"go.mod"}); err != nil {
+		t.Fatal(err)
+	}
+	// a pre-render action changes the project, then a template fails
+	if err := writeTestFile(dir, "go.mod", "module b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(dir, "tools/gen.go", "package tools"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dir); got != "go.mod=module a" {
+		t.Errorf("after rollback got %s", got)
+	}
+}
+
+func TestStagingCommitOverActionFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	if err := writeTestFile(dir, "keep.txt", "keep"); err != nil {
+		t.Fatal(err)
+	}
+	stage, err := NewStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+	// a pre-render action writes a file a template then generates
+	if err := writeTestFile(dir, "gen.go", "from action"); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := stage.Path(filepath.Join(dir, "gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(filepath.Dir(staged), "gen.go", "from template"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dir); got != "gen.go=from template keep.txt=keep" {
+		t.Fatalf("after commit got %s", got)
+	}
+	if err := stage.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dir); got != "keep.txt=keep" {
+		t.Errorf("after rollback got %s", got)
+	}
+}
+
+func TestStagingFreshRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "project")
+	stage, err := NewStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+	if err := writeTestFile(dir, "made.txt", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("fresh project folder left behind: %v", err)
+	}
+}
+
+func TestDiffStaging(t *testing.T) {
+	dest := t.TempDir()
+	dir := filepath.Join(dest, "project")
+	if err := writeTestFile(dir, "main.go", "old main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dest, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dest, 0755)
+	if err := ValidateFolder(dest, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateFolder(dest, true); err == nil {
+		t.Error("read-only destination accepted for writing")
+	}
+	stage, err := NewDiffStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stage.Cleanup()
+	staged, err := stage.Path(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(filepath.Dir(staged), "main.go", "new main"); err != nil {
+		t.Fatal(err)
+	}
+	if insideProject(dest, staged) {
+		t.Errorf("diff staged %s in the destination", staged)
+	}
+	if got := readTree(t, dest); got != "project/main.go=old main" {
+		t.Errorf("destination changed: %s", got)
+	}
+}
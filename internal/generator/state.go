@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StateName of the file inside the ManifestDir recording the inputs of actions that ran
+const StateName = "state.json"
+
+// State of the actions run in a project, so that actions whose inputs are unchanged can be skipped
+type State struct {
+	Inputs map[string]string `json:"inputs"`
+	dir    string
+	mu     sync.Mutex
+}
+
+// ReadState from a project folder, empty when there is none
+func ReadState(dir string) (*State, error) {
+	state := &State{Inputs: map[string]string{}, dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestDir, StateName))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("reading %s: %s", StateName, err)
+	}
+	if state.Inputs == nil {
+		state.Inputs = map[string]string{}
+	}
+	return state, nil
+}
+
+// Write the state into its project folder
+func (st *State) Write() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := MakeFolder(st.dir, ManifestDir); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(st.dir, ManifestDir, StateName), append(data, '\n'), 0644)
+}
+
+func stateKey(action, node string) string {
+	return action + " " + node
+}
+
+// InputsHash recorded the last time an action ran on a spec node
+func (st *State) InputsHash(action, node string) string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.Inputs[stateKey(action, node)]
+}
+
+// SetInputsHash of an action that ran successfully on a spec node
+func (st *State) SetInputsHash(action, node, hash string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.Inputs[stateKey(action, node)] = hash
+}
+
+// Glob finds the files below dir matching a slash separated pattern, where ** matches any number of folders.
+// The matches are sorted and relative to dir.
+func Glob(dir, pattern string) ([]string, error) {
+	segments := strings.Split(path.Clean(filepath.ToSlash(pattern)), "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("glob %s: %s", pattern, err)
+		}
+	}
+	// walk from the folder named before the first wildcard
+	base := 0
+	for base < len(segments) && !strings.ContainsAny(segments[base], `*?[\`) {
+		base++
+	}
+	root := filepath.Join(dir, filepath.FromSlash(strings.Join(segments[:base], "/")))
+	if base == len(segments) {
+		info, err := os.Stat(root)
+		if err != nil || info.IsDir() {
+			return nil, nil
+		}
+		return []string{filepath.FromSlash(pattern)}, nil
+	}
+	matches := []string{}
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if name != root && info.Name() == ManifestDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		if matchSegments(segments[base:], strings.Split(filepath.ToSlash(rel), "/")) {
+			rel, err := filepath.Rel(dir, name)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, rel)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+	return matches, err
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// HashGlobs hashes the patterns with the names and contents of every file below dir matching them.
+// The hash is empty when no file matches, as there is nothing to compare.
+func HashGlobs(dir string, patterns []string) (string, error) {
+	files := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := Glob(dir, pattern)
+		if err != nil {
+			return "", err
+		}
+		for _, match := range matches {
+			files[match] = true
+		}
+	}
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Strings(names)
+	sum := sha256.New()
+	for _, pattern := range patterns {
+		fmt.Fprintf(sum, "%s\n", filepath.ToSlash(pattern))
+	}
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(sum, "%s\x00%s\n", filepath.ToSlash(name), ContentHash(content))
+	}
+	return fmt.Sprintf("sha256:%x", sum.Sum(nil)), nil
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"go.mod",
+		"main.go",
+		"cmd/app/main.go",
+		"cmd/app/main_test.go",
+		"internal/a/b/c.go",
+		"internal/a/readme.md",
+		".brevity/state.json",
+		"sub/.brevity/manifest.json",
+	}
+	for _, file := range files {
+		if err := writeTestFile(dir, file, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		pattern string
+		want    string
+		err     bool
+	}{
+		{"go.mod", "go.mod", false},
+		{"missing.go", "", false},
+		{"cmd", "", false},
+		{"*.go", "main.go", false},
+		{"**/*.go", "cmd/app/main.go cmd/app/main_test.go internal/a/b/c.go main.go", false},
+		{"cmd/**/*_test.go", "cmd/app/main_test.go", false},
+		{"internal/**", "internal/a/b/c.go internal/a/readme.md", false},
+		{"internal/**/b/*.go", "internal/a/b/c.go", false},
+		{"**/a/*", "internal/a/readme.md", false},
+		{"**/*.json", "", false},
+		{"nothere/**/*.go", "", false},
+		{"[", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			matches, err := Glob(dir, test.pattern)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			got := filepath.ToSlash(strings.Join(matches, " "))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHashGlobs(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a.go", "b/c.go", "d.txt"} {
+		if err := writeTestFile(dir, file, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func(patterns ...string) string {
+		sum, err := HashGlobs(dir, patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	base := hash("**/*.go")
+	if base == "" {
+		t.Fatal("no hash for matching inputs")
+	}
+	if got := hash("**/*.go"); got != base {
+		t.Errorf("hash changed without a change to the inputs")
+	}
+	if got := hash("nothing/*.go"); got != "" {
+		t.Errorf("inputs matching nothing hashed to %s", got)
+	}
+	if got := hash("**/*.go", "*.md"); got == base {
+		t.Errorf("adding a glob did not change the hash")
+	}
+	if err := writeTestFile(dir, "b/c.go", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if got := hash("**/*.go"); got == base {
+		t.Errorf("changing an input did not change the hash")
+	}
+}
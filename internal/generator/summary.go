@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/robbyriverside/brief"
+)
+
+// On-error policies of an action
+const (
+	OnErrorFail   = "fail"
+	OnErrorWarn   = "warn"
+	OnErrorIgnore = "ignore"
+)
+
+// RetryBackoff before the first retry of an action, doubled for each retry after
+const RetryBackoff = time.Second
+
+// ActionOnError policy of an action from its on-error key, fail when it has none
+func ActionOnError(action *brief.Node) string {
+	policy, ok := action.Keys["on-error"]
+	if !ok {
+		return OnErrorFail
+	}
+	return policy
+}
+
+// ActionRetries of an action from its retries key
+func ActionRetries(action *brief.Node) (int, error) {
+	value, ok := action.Keys["retries"]
+	if !ok {
+		return 0, nil
+	}
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		return 0, fmt.Errorf("action %s retries must be a count: %s", action.Name, value)
+	}
+	return retries, nil
+}
+
+// Failure tolerated during a run, an action allowed to fail or a project skipped by --keep-going
+type Failure struct {
+	What   string
+	Policy string
+	Err    error
+}
+
+// Summary of the failures tolerated during a run, printed when it ends
+type Summary struct {
+	mu       sync.Mutex
+	Failures []*Failure
+}
+
+// Add a tolerated failure
+func (s *Summary) Add(what, policy string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failures = append(s.Failures, &Failure{What: what, Policy: policy, Err: err})
+}
+
+// Print the summary when anything failed
+func (s *Summary) Print(out io.Writer) {
+	if s == nil || len(s.Failures) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%d failures tolerated:\n", len(s.Failures))
+	for _, failure := range s.Failures {
+		fmt.Fprintf(out, "    %s (%s): %s\n", failure.What, failure.Policy, failure.Err)
+	}
+}
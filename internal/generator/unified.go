@@ -0,0 +1,207 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around each hunk
+const diffContext = 3
+
+type lineEdit struct {
+	op   byte
+	text string
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxDiffEdits bounds the edit search, beyond it the differing lines are replaced as a whole
+const maxDiffEdits = 2000
+
+// diffLines finds the shortest edit script between two sets of lines.
+// Lines the two have in common at either end are kept out of the search.
+func diffLines(a, b []string) []lineEdit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	edits := []lineEdit{}
+	for _, line := range a[:prefix] {
+		edits = append(edits, lineEdit{' ', line})
+	}
+	edits = append(edits, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, lineEdit{' ', line})
+	}
+	return edits
+}
+
+// myersDiff keeps only the diagonals each step reads when backtracking, so memory grows
+// with the square of the number of edits, which is bounded by maxDiffEdits
+func myersDiff(a, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds diagonals -d-1 to d+1 as they were before step d
+	trace := [][]int{}
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	edits := []lineEdit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d+1] < v[k+1+d+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, lineEdit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, lineEdit{'+', b[y-1]})
+			} else {
+				edits = append(edits, lineEdit{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// replaceLines removes every line of a and adds every line of b
+func replaceLines(a, b []string) []lineEdit {
+	edits := make([]lineEdit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, lineEdit{'-', line})
+	}
+	for _, line := range b {
+		edits = append(edits, lineEdit{'+', line})
+	}
+	return edits
+}
+
+// UnifiedDiff of two texts, empty when they are the same
+func UnifiedDiff(fromName, toName, from, to string) string {
+	edits := diffLines(splitLines(from), splitLines(to))
+	// line numbers in each file before each edit
+	aline := make([]int, len(edits)+1)
+	bline := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aline[i+1], bline[i+1] = aline[i], bline[i]
+		if edit.op != '+' {
+			aline[i+1]++
+		}
+		if edit.op != '-' {
+			bline[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	size := len(edits)
+	i := 0
+	for i < size {
+		for i < size && edits[i].op == ' ' {
+			i++
+		}
+		if i == size {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < size {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < size && edits[next].op == ' ' {
+				next++
+			}
+			if next == size || next-end > 2*diffContext {
+				if end+diffContext < next {
+					next = end + diffContext
+				}
+				end = next
+				break
+			}
+			end = next
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aline[start], aline[end]-aline[start]),
+			hunkRange(bline[start], bline[end]-bline[start]))
+		for _, edit := range edits[start:end] {
+			out.WriteByte(edit.op)
+			out.WriteString(edit.text)
+			if !strings.HasSuffix(edit.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	ten := numberedLines(1, 10)
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"same", ten, ten, ""},
+		{"empty", "", "", ""},
+		{"change in the middle", ten, strings.Replace(ten, "line 5\n", "five\n", 1),
+			"--- a\n+++ b\n@@ -2,7 +2,7 @@\n line 2\n line 3\n line 4\n-line 5\n+five\n line 6\n line 7\n line 8\n"},
+		{"change on the first line", ten, strings.Replace(ten, "line 1\n", "one\n", 1),
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-line 1\n+one\n line 2\n line 3\n line 4\n"},
+		{"append at the end", ten, ten + "line 11\n",
+			"--- a\n+++ b\n@@ -8,3 +8,4 @@\n line 8\n line 9\n line 10\n+line 11\n"},
+		{"added file", "", "a\nb\n",
+			"--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"removed content", "a\nb\n", "",
+			"--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"single line", "a\n", "b\n",
+			"--- a\n+++ b\n@@ -1 +1 @@\n-a\n+b\n"},
+		{"no newline at end", "a\nb", "a\nc",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"},
+		{"changes six lines apart share a hunk", numberedLines(1, 20),
+			strings.NewReplacer("line 5\n", "five\n", "line 12\n", "twelve\n").Replace(numberedLines(1, 20)),
+			"--- a\n+++ b\n@@ -2,14 +2,14 @@\n line 2\n line 3\n line 4\n-line 5\n+five\n line 6\n line 7\n line 8\n" +
+				" line 9\n line 10\n line 11\n-line 12\n+twelve\n line 13\n line 14\n line 15\n"},
+		{"changes seven lines apart split hunks", numberedLines(1, 20),
+			strings.NewReplacer("line 5\n", "five\n", "line 13\n", "thirteen\n").Replace(numberedLines(1, 20)),
+			"--- a\n+++ b\n@@ -2,7 +2,7 @@\n line 2\n line 3\n line 4\n-line 5\n+five\n line 6\n line 7\n line 8\n" +
+				"@@ -10,7 +10,7 @@\n line 10\n line 11\n line 12\n-line 13\n+thirteen\n line 14\n line 15\n line 16\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := UnifiedDiff("a", "b", test.from, test.to); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesEditScript(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"a b c", "a b c", " a  b  c"},
+		{"a b c", "a c", " a -b  c"},
+		{"a c", "a b c", " a +b  c"},
+		{"a b c a b b a", "c b a b a c", "-a -b  c +b  a  b -b  a +c"},
+	}
+	for _, test := range tests {
+		edits := diffLines(strings.Fields(test.from), strings.Fields(test.to))
+		parts := []string{}
+		for _, edit := range edits {
+			parts = append(parts, string(edit.op)+edit.text)
+		}
+		if got := strings.Join(parts, " "); got != test.want {
+			t.Errorf("diff %q %q got %q want %q", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestDiffLinesLargeRewrite(t *testing.T) {
+	from := strings.Fields(strings.Repeat("old ", maxDiffEdits))
+	to := strings.Fields(strings.Repeat("new ", maxDiffEdits))
+	from = append([]string{"same"}, from...)
+	to = append([]string{"same"}, to...)
+	edits := diffLines(from, to)
+	if len(edits) != 1+2*maxDiffEdits {
+		t.Fatalf("got %d edits", len(edits))
+	}
+	if edits[0].op != ' ' || edits[1].op != '-' || edits[maxDiffEdits].op != '-' || edits[maxDiffEdits+1].op != '+' {
+		t.Errorf("rewrite is not kept lines followed by removals then additions")
+	}
+}
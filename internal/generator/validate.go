@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/robbyriverside/brief"
+)
+
+// ValidateCommand checks a spec against its generators without writing anything
+type ValidateCommand struct {
+	Args struct {
+		SpecFile string `positional-arg-name:"specfile" description:"brevity specification file"`
+	} `positional-args:"true" required:"true"`
+	SpecOptions
+}
+
+// Execute the validate command
+func (vc *ValidateCommand) Execute(args []string) error {
+	cmd := &Command{}
+	spec, err := vc.ReadSpec(cmd, vc.Args.SpecFile, "")
+	if err != nil {
+		return err
+	}
+	problems := cmd.Validate(spec)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid brevity spec %s: %d problems found", vc.Args.SpecFile, len(problems))
+	}
+	return nil
+}
+
+// Validate every project and section in the spec, returning all problems found
+func (cmd *Command) Validate(spec *brief.Node) []error {
+	problems := []error{}
+	for i, project := range spec.Body {
+		if len(project.Name) == 0 {
+			problems = append(problems, fmt.Errorf("project %d: project must be named", i))
+			continue
+		}
+		if err := cmd.ExpandProjectMacros(project); err != nil {
+			problems = append(problems, fmt.Errorf("project %s: %s", project.Name, err))
+			continue
+		}
+		schedule := NewSchedule(project.Name)
+		compiled := true
+		for _, section := range project.Body {
+			gtor, errs := cmd.ValidateProjectSection(project, section)
+			for _, err := range errs {
+				problems = append(problems, fmt.Errorf("project %s: section %s:%s: %s", project.Name, section.Type, section.Name, err))
+			}
+			if gtor == nil {
+				compiled = false
+				continue
+			}
+			schedule.AddSection(gtor, project, section, "")
+		}
+		// action ordering spans the sections of a project
+		if compiled {
+			if err := schedule.Order(); err != nil {
+				problems = append(problems, fmt.Errorf("project %s: %s", project.Name, err))
+			}
+		}
+	}
+	return problems
+}
+
+// ValidateProjectSection compiles a section and checks it against its generator,
+// the generator is nil when the section does not compile
+func (cmd *Command) ValidateProjectSection(project, section *brief.Node) (*Generator, []error) {
+	gtor, err := cmd.CompileSection(context.Background(), section)
+	if err != nil {
+		return nil, []error{err}
+	}
+	problems := []error{}
+	if err := gtor.ValidateSection(section); err != nil {
+		problems = append(problems, err)
+	}
+	problems = append(problems, gtor.ValidateCatalog()...)
+	problems = append(problems, gtor.ValidateValues(project)...)
+	problems = append(problems, gtor.ValidateValues(section)...)
+	for _, subnode := range section.Body {
+		problems = append(problems, gtor.validateNodeValues(subnode)...)
+	}
+	return gtor, problems
+}
+
+// ValidateCatalog ensures every template in the catalog has a template definition
+func (gtor *Generator) ValidateCatalog() []error {
+	elems := []string{}
+	for elem := range gtor.Catalog {
+		elems = append(elems, elem)
+	}
+	// problems are reported in the same order every run
+	sort.Strings(elems)
+	problems := []error{}
+	for _, elem := range elems {
+		for _, tmpl := range gtor.Catalog[elem].Templates.List {
+			if gtor.Template.Lookup(tmpl.Name) == nil {
+				problems = append(problems, fmt.Errorf("no template found for %s on element %s", tmpl.Name, elem))
+			}
+		}
+	}
+	return problems
+}
+
+// ValidateValues expands the template and action values for a spec node
+func (gtor *Generator) ValidateValues(spec *brief.Node) []error {
+	agenda, ok := gtor.Catalog[spec.Type]
+	if !ok {
+		return nil
+	}
+	problems := []error{}
+	for _, tmpl := range agenda.Templates.List {
+		if _, err := gtor.ExecValueTemplate(tmpl.Keys["file"], spec); err != nil {
+			problems = append(problems, fmt.Errorf("template %s file on %s:%s: %s", tmpl.Name, spec.Type, spec.Name, err))
+		}
+	}
+	for _, action := range agenda.Actions.List {
+		if _, err := gtor.ActionArgs(action, spec); err != nil {
+			problems = append(problems, fmt.Errorf("action %s exec on %s:%s: %s", action.Name, spec.Type, spec.Name, err))
+		}
+		if _, err := gtor.ActionDir(action, spec, ""); err != nil {
+			problems = append(problems, fmt.Errorf("action %s dir on %s:%s: %s", action.Name, spec.Type, spec.Name, err))
+		}
+		if _, _, err := gtor.ActionEnv(action, spec); err != nil {
+			problems = append(problems, fmt.Errorf("action %s env on %s:%s: %s", action.Name, spec.Type, spec.Name, err))
+		}
+		if _, err := gtor.ExecValueTemplate(action.Keys["creates"], spec); err != nil {
+			problems = append(problems, fmt.Errorf("action %s creates on %s:%s: %s", action.Name, spec.Type, spec.Name, err))
+		}
+		for _, key := range []string{"inputs", "outputs"} {
+			if _, err := gtor.ActionGlobs(action, spec, key); err != nil {
+				problems = append(problems, fmt.Errorf("action %s on %s:%s: %s", action.Name, spec.Type, spec.Name, err))
+			}
+		}
+	}
+	return problems
+}
+
+func (gtor *Generator) validateNodeValues(node *brief.Node) []error {
+	problems := gtor.ValidateValues(node)
+	for _, subnode := range node.Body {
+		problems = append(problems, gtor.validateNodeValues(subnode)...)
+	}
+	return problems
+}
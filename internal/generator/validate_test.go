@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/robbyriverside/brief"
+)
+
+func TestValidateSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []string
+		spec     []string
+		want     string
+	}{
+		{"all found", []string{"cli", "command"}, []string{"command"}, ""},
+		{"one missing", []string{"cli", "command"}, nil, "invalid cli spec: missing elements [command]"},
+		{"missing in order", []string{"cli", "zeta", "alpha", "mid", "beta"}, []string{"mid"},
+			"invalid cli spec: missing elements [alpha beta zeta]"},
+		{"project is not an element", []string{"cli", "project"}, nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// run several times, as map order differs from run to run
+			for run := 0; run < 10; run++ {
+				gtor := &Generator{Catalog: Catalog{}}
+				for _, elem := range test.elements {
+					gtor.Catalog.Add(elem)
+				}
+				section := &brief.Node{Type: "cli", Keys: map[string]string{}}
+				for _, elem := range test.spec {
+					section.Body = append(section.Body, &brief.Node{Type: elem, Keys: map[string]string{}, Parent: section})
+				}
+				got := ""
+				if err := gtor.ValidateSection(section); err != nil {
+					got = err.Error()
+				}
+				if got != test.want {
+					t.Fatalf("got %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCatalog(t *testing.T) {
+	gtor := (&Command{}).New()
+	for _, elem := range []string{"zeta", "alpha", "mid"} {
+		gtor.Catalog.Add(elem).AddTemplate(&brief.Node{Type: "template", Name: elem + "-file", Keys: map[string]string{}})
+	}
+	for run := 0; run < 10; run++ {
+		got := fmt.Sprint(gtor.ValidateCatalog())
+		want := "[no template found for alpha-file on element alpha no template found for mid-file on element mid" +
+			" no template found for zeta-file on element zeta]"
+		if got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+}